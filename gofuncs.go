@@ -27,6 +27,26 @@ func exit(code int) {
 	os.Exit(code)
 }
 
+func args() []string {
+	return os.Args
+}
+
+func _stat(name string) int {
+	info, err := os.Stat(name)
+	if err != nil {
+		return -1
+	}
+	return int(info.Size())
+}
+
+func _readFile(name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 func char(ch int) string {
 	return string([]byte{byte(ch)})
 }
@@ -0,0 +1,12 @@
+//go:build ignore
+// +build ignore
+
+// Package errors is a Mugo implementation of a small subset of Go's errors
+// package.
+package errors
+
+// New returns an error that formats as the given text. Each call to New
+// returns a distinct error value even if the text is identical.
+func New(text string) error {
+	return _newError(text)
+}
@@ -0,0 +1,164 @@
+//go:build ignore
+// +build ignore
+
+// Package fileio reads and writes files using Linux system calls in the Mugo
+// runtime. Its API follows the file functions of Go's os package, but as
+// Mugo has no struct types, a File is a file descriptor and Stat returns
+// just the file's size. The Go version in gofileio.go wraps package os, so
+// programs that use fileio also run with "go run".
+package fileio
+
+import "errors"
+
+// Flags for OpenFile. Mugo has no "|" operator, so combine them with "+",
+// as in O_WRONLY+O_APPEND.
+const (
+	O_RDONLY int = 0
+	O_WRONLY int = 1
+	O_RDWR   int = 2
+	O_CREATE int = 64
+	O_EXCL   int = 128
+	O_TRUNC  int = 512
+	O_APPEND int = 1024
+)
+
+var (
+	// EOF is the error Read returns when no more input is available.
+	EOF error
+)
+
+func init() {
+	EOF = errors.New("EOF")
+}
+
+// A File is an open file's descriptor.
+type File int
+
+// Return n as a decimal string.
+func decimal(n int) string {
+	if n < 10 {
+		return char(n + '0')
+	}
+	return decimal(n/10) + char(n%10+'0')
+}
+
+// Return the message for the errno negated in system call result r.
+func errnoText(r int) string {
+	errno := -r
+	if errno == 2 {
+		return "no such file or directory"
+	} else if errno == 9 {
+		return "bad file descriptor"
+	} else if errno == 13 {
+		return "permission denied"
+	} else if errno == 17 {
+		return "file exists"
+	} else if errno == 20 {
+		return "not a directory"
+	} else if errno == 21 {
+		return "is a directory"
+	} else if errno == 28 {
+		return "no space left on device"
+	}
+	return "errno " + decimal(errno)
+}
+
+// Return the error for system call result r from operation op on the
+// named file.
+func pathError(op string, name string, r int) error {
+	return errors.New(op + " " + name + ": " + errnoText(r))
+}
+
+// OpenFile opens the named file with the given flags (O_RDONLY etc.) and,
+// if it's created, permissions.
+func OpenFile(name string, flag int, perm int) (File, error) {
+	fd := _openFile(name, flag, perm)
+	if fd < 0 {
+		return -1, pathError("open", name, fd)
+	}
+	return File(fd), nil
+}
+
+// Open opens the named file for reading.
+func Open(name string) (File, error) {
+	return OpenFile(name, O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file, with permissions 0666 (before
+// umask) if it's created.
+func Create(name string) (File, error) {
+	return OpenFile(name, O_RDWR+O_CREATE+O_TRUNC, 0666)
+}
+
+// Read reads up to len(b) bytes into b and returns the number of bytes
+// read. At end of file, Read returns 0, EOF.
+func (f File) Read(b []byte) (int, error) {
+	n := _read(int(f), b)
+	if n < 0 {
+		return 0, errors.New("read: " + errnoText(n))
+	}
+	if n == 0 {
+		if len(b) > 0 {
+			return 0, EOF
+		}
+	}
+	return n, nil
+}
+
+// WriteString writes the contents of string s to the file and returns
+// the number of bytes written.
+func (f File) WriteString(s string) (int, error) {
+	n := _write(int(f), s)
+	if n < 0 {
+		return 0, errors.New("write: " + errnoText(n))
+	}
+	return n, nil
+}
+
+// Write is like WriteString, but writes the bytes in b.
+func (f File) Write(b []byte) (int, error) {
+	return f.WriteString(string(b))
+}
+
+// Close closes the file.
+func (f File) Close() error {
+	r := _close(int(f))
+	if r < 0 {
+		return errors.New("close: " + errnoText(r))
+	}
+	return nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func ReadFile(name string) ([]byte, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return []byte(_readFile(name)), nil
+}
+
+// WriteFile writes data to the named file, creating it with permissions
+// perm if necessary, or truncating it otherwise.
+func WriteFile(name string, data []byte, perm int) error {
+	f, err := OpenFile(name, O_WRONLY+O_CREATE+O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Stat returns the size in bytes of the named file.
+func Stat(name string) (int, error) {
+	size := _stat(name)
+	if size < 0 {
+		return 0, pathError("stat", name, size)
+	}
+	return size, nil
+}
@@ -0,0 +1,62 @@
+// Package fileio is the Go version of the Mugo package in fileio.go (see its
+// documentation), so that programs that use it also run with "go run".
+package fileio
+
+import (
+	"io"
+	"os"
+)
+
+// Flags for OpenFile.
+const (
+	O_RDONLY int = os.O_RDONLY
+	O_WRONLY int = os.O_WRONLY
+	O_RDWR   int = os.O_RDWR
+	O_CREATE int = os.O_CREATE
+	O_EXCL   int = os.O_EXCL
+	O_TRUNC  int = os.O_TRUNC
+	O_APPEND int = os.O_APPEND
+)
+
+// EOF is the error Read returns when no more input is available.
+var EOF = io.EOF
+
+// A File is an open file.
+type File = *os.File
+
+// OpenFile opens the named file with the given flags (O_RDONLY etc.) and,
+// if it's created, permissions.
+func OpenFile(name string, flag int, perm int) (File, error) {
+	return os.OpenFile(name, flag, os.FileMode(perm))
+}
+
+// Open opens the named file for reading.
+func Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+// Create creates or truncates the named file, with permissions 0666 (before
+// umask) if it's created.
+func Create(name string) (File, error) {
+	return os.Create(name)
+}
+
+// ReadFile reads the named file and returns its contents.
+func ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile writes data to the named file, creating it with permissions
+// perm if necessary, or truncating it otherwise.
+func WriteFile(name string, data []byte, perm int) error {
+	return os.WriteFile(name, data, os.FileMode(perm))
+}
+
+// Stat returns the size in bytes of the named file.
+func Stat(name string) (int, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	return int(info.Size()), nil
+}
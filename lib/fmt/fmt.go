@@ -0,0 +1,251 @@
+//go:build ignore
+// +build ignore
+
+// Package fmt is a Mugo implementation of a small subset of Go's fmt
+// package. Arguments may be strings, integers, float64s, bools, errors or
+// nil (values of named types are formatted as their underlying type).
+package fmt
+
+import "strconv"
+
+func formatBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// Return arg in decimal and the name of its type if it's a signed integer,
+// or "", "" if not.
+func formatSigned(arg any) (string, string) {
+	n, ok := arg.(int)
+	if ok {
+		return strconv.Itoa(n), "int"
+	}
+	i8, ok := arg.(int8)
+	if ok {
+		return strconv.Itoa(int(i8)), "int8"
+	}
+	i16, ok := arg.(int16)
+	if ok {
+		return strconv.Itoa(int(i16)), "int16"
+	}
+	i32, ok := arg.(int32)
+	if ok {
+		return strconv.Itoa(int(i32)), "int32"
+	}
+	i64, ok := arg.(int64)
+	if ok {
+		return strconv.FormatInt(i64, 10), "int64"
+	}
+	return "", ""
+}
+
+// Return arg in decimal and the name of its type if it's an unsigned
+// integer, or "", "" if not.
+func formatUnsigned(arg any) (string, string) {
+	u8, ok := arg.(uint8)
+	if ok {
+		return strconv.Itoa(int(u8)), "uint8"
+	}
+	u16, ok := arg.(uint16)
+	if ok {
+		return strconv.Itoa(int(u16)), "uint16"
+	}
+	u32, ok := arg.(uint32)
+	if ok {
+		return strconv.Itoa(int(u32)), "uint32"
+	}
+	u64, ok := arg.(uint64)
+	if ok {
+		return strconv.FormatUint(u64, 10), "uint64"
+	}
+	u, ok := arg.(uint)
+	if ok {
+		return strconv.FormatUint(uint64(u), 10), "uint"
+	}
+	p, ok := arg.(uintptr)
+	if ok {
+		return strconv.FormatUint(uint64(p), 10), "uintptr"
+	}
+	return "", ""
+}
+
+// Return arg formatted with the %v verb and the name of its type if it's
+// a number, or "", "" if not.
+func formatNumber(arg any) (string, string) {
+	s, name := formatSigned(arg)
+	if name != "" {
+		return s, name
+	}
+	f, ok := arg.(float64)
+	if ok {
+		return strconv.FormatFloat(f, 'g', -1, 64), "float64"
+	}
+	return formatUnsigned(arg)
+}
+
+// Return arg formatted with the %v verb and the name of its type (as
+// shown in bad verb and EXTRA messages).
+func formatValue(arg any) (string, string) {
+	if arg == nil {
+		return "<nil>", ""
+	}
+	s, ok := arg.(string)
+	if ok {
+		return s, "string"
+	}
+	s, name := formatNumber(arg)
+	if name != "" {
+		return s, name
+	}
+	b, ok := arg.(bool)
+	if ok {
+		return formatBool(b), "bool"
+	}
+	err, ok := arg.(error)
+	if ok {
+		return err.Error(), "*errors.errorString"
+	}
+	return "?", "?"
+}
+
+// Return true if name is the name of an integer type.
+func isInteger(name string) bool {
+	return name == "int" || name == "int8" || name == "int16" || name == "int32" ||
+		name == "int64" || name == "uint" || name == "uint8" || name == "uint16" ||
+		name == "uint32" || name == "uint64" || name == "uintptr"
+}
+
+// Return the rune with the code point given by the decimal integer s, or
+// U+FFFD if it's not a valid code point.
+func toRune(s string) rune {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 1114111 {
+		return 65533
+	}
+	return rune(n)
+}
+
+// Return arg's type and value, as shown in bad verb and EXTRA messages.
+func typeAndValue(arg any) string {
+	s, name := formatValue(arg)
+	if name == "" {
+		return s
+	}
+	return name + "=" + s
+}
+
+// Return arg formatted according to verb and precision prec (-1 if none
+// was given; only used for floats).
+func formatArg(verb int, prec int, arg any) string {
+	s, name := formatValue(arg)
+	if verb == 'v' || verb == 's' && name == "string" {
+		return s
+	} else if verb == 'q' && name == "string" {
+		return strconv.Quote(s)
+	} else if verb == 'd' && isInteger(name) {
+		return s
+	} else if verb == 'c' && isInteger(name) {
+		return string(toRune(s))
+	} else if verb == 'q' && isInteger(name) {
+		return strconv.QuoteRune(toRune(s))
+	} else if verb == 't' && name == "bool" {
+		return s
+	} else if name == "float64" {
+		if prec < 0 && verb != 'g' {
+			prec = 6
+		}
+		f, _ := arg.(float64)
+		if verb == 'e' || verb == 'f' || verb == 'g' {
+			return strconv.FormatFloat(f, verb, prec, 64)
+		}
+	} else if name == "*errors.errorString" {
+		if verb == 's' {
+			return s
+		} else if verb == 'q' {
+			return strconv.Quote(s)
+		}
+		// Go formats the pointer to the error's struct
+		return "&{%!" + char(verb) + "(string=" + s + ")}"
+	}
+	return "%!" + char(verb) + "(" + typeAndValue(arg) + ")"
+}
+
+// Sprintf formats according to a format specifier and returns the resulting
+// string. Supported verbs are %v, %d, %s, %q, %c, %t, %e, %f, %g and %%, and
+// the floating-point verbs may have a precision, as in %.2f.
+func Sprintf(format string, a ...any) string {
+	s := ""
+	n := 0 // index of next argument
+	i := 0
+	for i < len(format) {
+		ch := int(format[i])
+		if ch != '%' {
+			s = s + char(ch)
+		} else if i+1 == len(format) {
+			s = s + "%!(NOVERB)"
+		} else {
+			i = i + 1
+			prec := -1
+			if format[i] == '.' {
+				prec = 0
+				i = i + 1
+				for i < len(format)-1 && format[i] >= '0' && format[i] <= '9' {
+					prec = prec*10 + int(format[i]) - '0'
+					i = i + 1
+				}
+			}
+			verb := int(format[i])
+			if verb == '%' {
+				s = s + "%"
+			} else if n >= len(a) {
+				s = s + "%!" + char(verb) + "(MISSING)"
+			} else {
+				s = s + formatArg(verb, prec, a[n])
+				n = n + 1
+			}
+		}
+		i = i + 1
+	}
+	if n < len(a) {
+		s = s + "%!(EXTRA "
+		for n < len(a) {
+			s = s + typeAndValue(a[n])
+			n = n + 1
+			if n < len(a) {
+				s = s + ", "
+			}
+		}
+		s = s + ")"
+	}
+	return s
+}
+
+// Sprintln formats its arguments with spaces between them and a newline at
+// the end, and returns the resulting string.
+func Sprintln(a ...any) string {
+	s := ""
+	i := 0
+	for i < len(a) {
+		if i > 0 {
+			s = s + " "
+		}
+		v, _ := formatValue(a[i])
+		s = s + v
+		i = i + 1
+	}
+	return s + "\n"
+}
+
+// Printf formats according to a format specifier and writes to standard
+// output.
+func Printf(format string, a ...any) {
+	print(Sprintf(format, a...))
+}
+
+// Println formats its arguments with spaces between them and a newline at
+// the end, and writes to standard output.
+func Println(a ...any) {
+	print(Sprintln(a...))
+}
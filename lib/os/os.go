@@ -0,0 +1,65 @@
+//go:build ignore
+// +build ignore
+
+// Package os is a Mugo implementation of a small subset of Go's os package.
+// Args is set before main is called.
+package os
+
+var (
+	// Args holds the command-line arguments, starting with the program name.
+	Args []string
+)
+
+// Exit causes the current program to exit with the given status code.
+func Exit(code int) {
+	exit(code)
+}
+
+// Environ returns a copy of strings representing the environment, in the
+// form "key=value".
+func Environ() []string {
+	return _environ()
+}
+
+// Report whether kv (of the form "key=value") has the given key.
+func hasKey(kv string, key string) bool {
+	if len(kv) <= len(key) {
+		return false
+	}
+	if kv[len(key)] != '=' {
+		return false
+	}
+	i := 0
+	for i < len(key) {
+		if kv[i] != key[i] {
+			return false
+		}
+		i = i + 1
+	}
+	return true
+}
+
+// Return the value part of kv (of the form "key=value").
+func value(kv string, key string) string {
+	b := make([]byte, len(kv)-len(key)-1)
+	i := 0
+	for i < len(b) {
+		b[i] = kv[len(key)+1+i]
+		i = i + 1
+	}
+	return string(b)
+}
+
+// Getenv retrieves the value of the environment variable named by the key.
+// It returns the value, which will be empty if the variable is not present.
+func Getenv(key string) string {
+	env := _environ()
+	i := 0
+	for i < len(env) {
+		if hasKey(env[i], key) {
+			return value(env[i], key)
+		}
+		i = i + 1
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+//go:build ignore
+// +build ignore
+
+// Package runtime is a Mugo implementation of a tiny subset of Go's runtime
+// package.
+package runtime
+
+// GC runs a garbage collection.
+func GC() {
+	_gc()
+}
@@ -0,0 +1,473 @@
+//go:build ignore
+// +build ignore
+
+// Package strconv is a Mugo implementation of a small subset of Go's strconv
+// package.
+package strconv
+
+// Itoa returns the decimal string representation of i.
+func Itoa(i int) string {
+	// Work with negative numbers so that the most negative int works too
+	neg := i < 0
+	if !neg {
+		i = -i
+	}
+	s := ""
+	for i <= -10 {
+		s = char('0'-i%10) + s
+		i = i / 10
+	}
+	s = char('0'-i) + s
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// FormatUint returns the string representation of i in the given base,
+// which must be between 2 and 36.
+func FormatUint(i uint64, base int) string {
+	digits := "0123456789abcdefghijklmnopqrstuvwxyz"
+	b := uint64(base)
+	s := ""
+	for i >= b {
+		s = char(int(digits[i%b])) + s
+		i = i / b
+	}
+	return char(int(digits[i])) + s
+}
+
+// FormatInt returns the string representation of i in the given base,
+// which must be between 2 and 36.
+func FormatInt(i int64, base int) string {
+	if i < 0 {
+		return "-" + FormatUint(uint64(-i), base)
+	}
+	return FormatUint(uint64(i), base)
+}
+
+func hexDigit(n int) string {
+	if n < 10 {
+		return char('0' + n)
+	}
+	return char('a' + n - 10)
+}
+
+// Return ch (a byte) as it should appear inside a double-quoted string.
+func quoteByte(ch int) string {
+	if ch == '"' {
+		return "\\\""
+	} else if ch == '\\' {
+		return "\\\\"
+	} else if ch == 7 {
+		return "\\a"
+	} else if ch == 8 {
+		return "\\b"
+	} else if ch == 12 {
+		return "\\f"
+	} else if ch == '\n' {
+		return "\\n"
+	} else if ch == '\r' {
+		return "\\r"
+	} else if ch == '\t' {
+		return "\\t"
+	} else if ch == 11 {
+		return "\\v"
+	} else if ch < ' ' || ch == 127 {
+		return "\\x" + hexDigit(ch/16) + hexDigit(ch%16)
+	}
+	return char(ch)
+}
+
+// Quote returns a double-quoted Go string literal representing s. Control
+// characters are escaped, but bytes >= 0x80 are copied as is (s is assumed
+// to be valid UTF-8 with only printable characters).
+func Quote(s string) string {
+	q := "\""
+	i := 0
+	for i < len(s) {
+		q = q + quoteByte(int(s[i]))
+		i = i + 1
+	}
+	return q + "\""
+}
+
+// QuoteRune returns a single-quoted Go character literal representing the
+// rune r. Invalid runes are converted to U+FFFD, and runes >= 0xA0 are
+// assumed to be printable.
+func QuoteRune(r rune) string {
+	if r < 0 || r > 1114111 || r >= 55296 && r < 57344 {
+		r = 65533 // utf8.RuneError
+	}
+	if r == '\'' {
+		return "'\\''"
+	} else if r == '"' {
+		return "'\"'"
+	} else if r < 128 {
+		return "'" + quoteByte(int(r)) + "'"
+	} else if r < 160 {
+		return "'\\u00" + hexDigit(int(r/16)) + hexDigit(int(r%16)) + "'"
+	}
+	return "'" + string(r) + "'"
+}
+
+func numError(fn string, s string, msg string) error {
+	return _newError("strconv." + fn + ": parsing " + Quote(s) + ": " + msg)
+}
+
+// Atoi returns the result of parsing s as a decimal integer with an optional
+// sign. If s is out of range, it returns the nearest int and an error.
+func Atoi(s string) (int, error) {
+	neg := false
+	i := 0
+	if len(s) > 0 {
+		if s[0] == '-' || s[0] == '+' {
+			neg = s[0] == '-'
+			i = 1
+		}
+	}
+	if i == len(s) {
+		return 0, numError("Atoi", s, "invalid syntax")
+	}
+	// Accumulate as a negative number so that the most negative int fits
+	n := 0
+	overflow := false
+	for i < len(s) {
+		d := int(s[i]) - '0'
+		if d < 0 || d > 9 {
+			return 0, numError("Atoi", s, "invalid syntax")
+		}
+		if n < -922337203685477580 || n == -922337203685477580 && d > 8 {
+			overflow = true
+		} else {
+			n = n*10 - d
+		}
+		i = i + 1
+	}
+	if !neg && n == -9223372036854775807-1 {
+		overflow = true
+	}
+	if overflow {
+		if neg {
+			return -9223372036854775807 - 1, numError("Atoi", s, "value out of range")
+		}
+		return 9223372036854775807, numError("Atoi", s, "value out of range")
+	}
+	if !neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// Multiply big integer x (decimal digits, least significant first) by m.
+func mul(x []int, m int) []int {
+	carry := 0
+	i := 0
+	for i < len(x) {
+		n := x[i]*m + carry
+		x[i] = n % 10
+		carry = n / 10
+		i = i + 1
+	}
+	for carry > 0 {
+		x = append(x, carry%10)
+		carry = carry / 10
+	}
+	return x
+}
+
+// Multiply big integer x by base^k.
+func mulPow(x []int, base int, k int) []int {
+	for k > 0 {
+		// Multiply by up to base^13 at a time (small enough not to overflow)
+		m := 1
+		i := 0
+		for i < 13 && i < k {
+			m = m * base
+			i = i + 1
+		}
+		x = mul(x, m)
+		k = k - i
+	}
+	return x
+}
+
+// Remove trailing zeros from decimal d.
+func trim(d []int) []int {
+	for len(d) > 1 && d[len(d)-1] == 0 {
+		d = d[:len(d)-1]
+	}
+	if len(d) == 1 {
+		d[0] = 0 // zero
+	}
+	return d
+}
+
+// Return the exact decimal representation of n * 2^exp (n >= 0). A decimal
+// is a []int holding the position of the decimal point, followed by the
+// digits, most significant first and without trailing zeros.
+func newDecimal(n int, exp int) []int {
+	var x []int
+	for n > 0 {
+		x = append(x, n%10)
+		n = n / 10
+	}
+	exp10 := 0
+	if exp < 0 {
+		// n * 2^exp is n * 5^-exp / 10^-exp
+		x = mulPow(x, 5, -exp)
+		exp10 = exp
+	} else {
+		x = mulPow(x, 2, exp)
+	}
+	var d []int
+	d = append(d, len(x)+exp10)
+	i := len(x) - 1
+	for i >= 0 {
+		d = append(d, x[i])
+		i = i - 1
+	}
+	return trim(d)
+}
+
+// Return digit i of decimal d (counting from 0), or 0 if out of range.
+func digit(d []int, i int) int {
+	if i < 0 || i >= len(d)-1 {
+		return 0
+	}
+	return d[i+1]
+}
+
+// Round decimal d up to nd digits.
+func roundUp(d []int, nd int) []int {
+	i := nd - 1
+	for i >= 0 {
+		if d[i+1] < 9 {
+			d[i+1] = d[i+1] + 1
+			return d[:i+2]
+		}
+		i = i - 1
+	}
+	// Number is all 9s: change to single 1 with adjusted decimal point
+	d[1] = 1
+	d[0] = d[0] + 1
+	return d[:2]
+}
+
+// Round decimal d to nd digits (rounding half to even).
+func round(d []int, nd int) []int {
+	if nd < 0 || nd >= len(d)-1 {
+		return d
+	}
+	if d[nd+1] == 5 && nd+2 == len(d) {
+		// Exactly halfway: round to even
+		if nd > 0 && d[nd]%2 == 1 {
+			return roundUp(d, nd)
+		}
+		return trim(d[:nd+1])
+	}
+	if d[nd+1] >= 5 {
+		return roundUp(d, nd)
+	}
+	return trim(d[:nd+1])
+}
+
+// Round decimal d to the shortest number of digits that's still between
+// the decimals upper and lower (inclusive if inclusive is true).
+func roundBetween(d []int, upper []int, lower []int, inclusive bool) []int {
+	upperDelta := 0 // 0, 1, or 2 (2 meaning "more than 1")
+	ui := 0
+	for ui-upper[0]+d[0] < len(d)-1 {
+		mi := ui - upper[0] + d[0]
+		li := ui - upper[0] + lower[0]
+		m := digit(d, mi)
+		u := digit(upper, ui)
+		okDown := digit(lower, li) != m || inclusive && li+2 == len(lower)
+		if upperDelta == 0 && m+1 < u {
+			upperDelta = 2
+		} else if upperDelta == 0 && m != u {
+			upperDelta = 1
+		} else if upperDelta == 1 && m != 9 || upperDelta == 1 && u != 0 {
+			upperDelta = 2
+		}
+		okUp := upperDelta > 0 && inclusive || upperDelta > 1 ||
+			upperDelta > 0 && ui+2 < len(upper)
+		if okDown && okUp {
+			return round(d, mi+1)
+		} else if okDown {
+			return trim(d[:mi+2])
+		} else if okUp {
+			return roundUp(d, mi+1)
+		}
+		ui = ui + 1
+	}
+	return d
+}
+
+// Round decimal d, the exact value of mant * 2^(exp-52), to the shortest
+// number of digits that converts back to the same float64.
+func roundShortest(d []int, mant int, exp int) []int {
+	if mant == 0 {
+		return d
+	}
+	dexp := d[0] - len(d) + 1
+	if exp > -1022 && 332*dexp >= 100*exp-5200 {
+		return d // already shortest
+	}
+	// Values halfway to the next float64 up and down round to this one
+	upper := newDecimal(mant*2+1, exp-53)
+	var lower []int
+	if mant > 4503599627370496 || exp == -1022 {
+		lower = newDecimal(mant*2-1, exp-53)
+	} else {
+		lower = newDecimal(mant*4-1, exp-54)
+	}
+	return roundBetween(d, upper, lower, mant%2 == 0)
+}
+
+// Format decimal d as -d.ddddde±dd with prec digits after the point.
+func formatE(d []int, prec int) string {
+	s := char('0' + digit(d, 0))
+	i := 1
+	if prec > 0 {
+		s = s + "."
+		for i <= prec {
+			s = s + char('0'+digit(d, i))
+			i = i + 1
+		}
+	}
+	exp := d[0] - 1
+	if len(d) == 1 {
+		exp = 0
+	}
+	if exp < 0 {
+		s = s + "e-"
+		exp = -exp
+	} else {
+		s = s + "e+"
+	}
+	if exp < 10 {
+		s = s + "0"
+	}
+	return s + Itoa(exp)
+}
+
+// Format decimal d as -ddddd.ddddd with prec digits after the point.
+func formatF(d []int, prec int) string {
+	s := "0"
+	i := 0
+	if d[0] > 0 {
+		s = ""
+		for i < d[0] {
+			s = s + char('0'+digit(d, i))
+			i = i + 1
+		}
+	}
+	if prec > 0 {
+		s = s + "."
+		i = 1
+		for i <= prec {
+			s = s + char('0'+digit(d, d[0]+i-1))
+			i = i + 1
+		}
+	}
+	return s
+}
+
+// Format decimal d for %g with precision prec (shortest is true if the
+// digits are the shortest representation).
+func formatG(d []int, prec int, shortest bool) string {
+	nd := len(d) - 1
+	eprec := prec
+	if eprec > nd && nd >= d[0] {
+		eprec = nd
+	}
+	if shortest {
+		eprec = 6
+	}
+	exp := d[0] - 1
+	if exp < -4 || exp >= eprec {
+		if prec > nd {
+			prec = nd
+		}
+		return formatE(d, prec-1)
+	}
+	if prec > d[0] {
+		prec = nd
+	}
+	if prec < d[0] {
+		return formatF(d, 0)
+	}
+	return formatF(d, prec-d[0])
+}
+
+// Round and format decimal d according to format fmt and precision prec (as
+// for FormatFloat).
+func formatDecimal(d []int, fmt int, prec int) string {
+	shortest := prec < 0
+	nd := len(d) - 1
+	if shortest {
+		prec = nd
+		if fmt == 'e' {
+			prec = nd - 1
+		} else if fmt == 'f' {
+			prec = nd - d[0]
+			if prec < 0 {
+				prec = 0
+			}
+		}
+	} else if fmt == 'e' {
+		d = round(d, prec+1)
+	} else if fmt == 'f' {
+		d = round(d, d[0]+prec)
+	} else if fmt == 'g' {
+		if prec == 0 {
+			prec = 1
+		}
+		d = round(d, prec)
+	}
+	if fmt == 'e' {
+		return formatE(d, prec)
+	} else if fmt == 'f' {
+		return formatF(d, prec)
+	} else if fmt == 'g' {
+		return formatG(d, prec, shortest)
+	}
+	return "%" + char(fmt)
+}
+
+// FormatFloat converts the floating-point number f to a string, according
+// to the format fmt ('e', 'f' or 'g') and precision prec. The special
+// precision -1 uses the smallest number of digits necessary to represent
+// the value uniquely. Only a bitSize of 64 is supported.
+func FormatFloat(f float64, fmt int, prec int, bitSize int) string {
+	bits := _floatBits(f)
+	neg := bits < 0
+	if neg {
+		bits = bits + 9223372036854775807 + 1 // clear sign bit
+	}
+	exp := bits / 4503599627370496 // biased exponent (bits 52-62)
+	mant := bits % 4503599627370496
+	if exp == 2047 {
+		if mant != 0 {
+			return "NaN"
+		} else if neg {
+			return "-Inf"
+		}
+		return "+Inf"
+	}
+	if exp == 0 {
+		exp = 1 // denormalized
+	} else {
+		mant = mant + 4503599627370496 // add implicit top bit
+	}
+	exp = exp - 1023
+	d := newDecimal(mant, exp-52)
+	if prec < 0 {
+		d = roundShortest(d, mant, exp)
+	}
+	if neg {
+		return "-" + formatDecimal(d, fmt, prec)
+	}
+	return formatDecimal(d, fmt, prec)
+}
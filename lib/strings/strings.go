@@ -0,0 +1,149 @@
+//go:build ignore
+// +build ignore
+
+// Package strings is a Mugo implementation of a small subset of Go's strings
+// package.
+package strings
+
+// Report whether s contains substr at byte offset i.
+func hasAt(s string, i int, substr string) bool {
+	if i+len(substr) > len(s) {
+		return false
+	}
+	j := 0
+	for j < len(substr) {
+		if s[i+j] != substr[j] {
+			return false
+		}
+		j = j + 1
+	}
+	return true
+}
+
+// Return the bytes of s from start up to (but not including) end.
+func substring(s string, start int, end int) string {
+	t := ""
+	for start < end {
+		t = t + char(int(s[start]))
+		start = start + 1
+	}
+	return t
+}
+
+// Index returns the index of the first instance of substr in s, or -1 if
+// substr is not present in s.
+func Index(s string, substr string) int {
+	i := 0
+	for i+len(substr) <= len(s) {
+		if hasAt(s, i, substr) {
+			return i
+		}
+		i = i + 1
+	}
+	return -1
+}
+
+// HasPrefix reports whether the string s begins with prefix.
+func HasPrefix(s string, prefix string) bool {
+	return hasAt(s, 0, prefix)
+}
+
+// Compare returns an integer comparing two strings lexicographically. The
+// result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+func Compare(a string, b string) int {
+	if a == b {
+		return 0
+	} else if a < b {
+		return -1
+	}
+	return 1
+}
+
+// Split slices s into all substrings separated by sep and returns a slice of
+// the substrings between those separators. If sep is empty, Split splits
+// after each byte (Go splits after each UTF-8 sequence).
+func Split(s string, sep string) []string {
+	var parts []string
+	i := 0
+	if len(sep) == 0 {
+		for i < len(s) {
+			parts = append(parts, char(int(s[i])))
+			i = i + 1
+		}
+		return parts
+	}
+	start := 0
+	for i+len(sep) <= len(s) {
+		if hasAt(s, i, sep) {
+			parts = append(parts, substring(s, start, i))
+			i = i + len(sep)
+			start = i
+		} else {
+			i = i + 1
+		}
+	}
+	return append(parts, substring(s, start, len(s)))
+}
+
+// Join concatenates the elements of elems to create a single string. The
+// separator string sep is placed between elements in the resulting string.
+func Join(elems []string, sep string) string {
+	if len(elems) == 0 {
+		return ""
+	}
+	s := elems[0]
+	i := 1
+	for i < len(elems) {
+		s = s + sep + elems[i]
+		i = i + 1
+	}
+	return s
+}
+
+// A Builder is used to efficiently build a string using its Write methods.
+// Mugo has no structs, so it's the list of strings written so far.
+type Builder []string
+
+// WriteString appends the contents of s to b's buffer. It returns the length
+// of s and a nil error.
+func (b *Builder) WriteString(s string) (int, error) {
+	*b = append(*b, s)
+	return len(s), nil
+}
+
+// WriteByte appends the byte c to b's buffer. It returns a nil error.
+func (b *Builder) WriteByte(c byte) error {
+	*b = append(*b, char(int(c)))
+	return nil
+}
+
+// WriteRune appends the UTF-8 encoding of r to b's buffer. It returns the
+// length of the encoding and a nil error.
+func (b *Builder) WriteRune(r rune) (int, error) {
+	s := string(r)
+	*b = append(*b, s)
+	return len(s), nil
+}
+
+// String returns the accumulated string.
+func (b *Builder) String() string {
+	return Join(*b, "")
+}
+
+// Len returns the number of accumulated bytes.
+func (b *Builder) Len() int {
+	parts := *b
+	n := 0
+	i := 0
+	for i < len(parts) {
+		n = n + len(parts[i])
+		i = i + 1
+	}
+	return n
+}
+
+// Reset resets the Builder to be empty.
+func (b *Builder) Reset() {
+	parts := *b
+	*b = parts[:0]
+}
@@ -12,25 +12,130 @@ var (
 	token          int      // current parser token
 	tokenInt       int      // integer value of current token (if applicable)
 	tokenStr       string   // string value of current token (if applicable)
+	nextToken      int      // token pushed back by unreadIdent, or 0
+	nextTokenInt   int      // integer value of pushed back token
+	nextTokenStr   string   // string value of pushed back token
 	curFunc        string   // current function name, or "" if not in a func
+	curPackage     string   // current package name
+	fileNames      []string // input file names, starting with "" for stdin
+	curFile        int      // index in fileNames of file being compiled
+	input          string   // contents of file being compiled (not stdin)
+	inputPos       int      // index of next character in input
+	packages       []string // names of packages compiled so far
+	imports        []string // names of packages imported by current file
 	tokens         []string // token names
 	types          []string // type names
 	typeSizes      []int    // type sizes in bytes
+	typeKinds      []int    // kindBasic, kindSlice, etc
+	typeElems      []int    // element type (pointer or slice), underlying type (named), index into tupleTypes or funcTypeSigs, or constraint (type parameter)
+	tupleTypes     []int    // for each tuple type: N type1 ... typeN
 	labelNum       int      // current label number
-	consts         []string // constant names
+	breakLabels    []string // labels of enclosing loops for "break"
+	contLabels     []string // labels of enclosing loops for "continue"
+	loopNames      []string // user labels of enclosing loops, or ""
+	loopDepths     []int    // range depth inside each enclosing loop
+	loopName       string   // user label of "for" being parsed, or ""
+	rangeDepth     int      // number of enclosing "range" loops
+	blockCount     int      // number of blocks opened in current function
+	openBlocks     []int    // numbers of enclosing blocks, innermost last
+	labelNames     []string // user labels defined in current function
+	labelBlocks    []int    // block each label was defined in
+	labelUsed      []int    // 1 if label is used, 0 if not
+	gotoNames      []string // labels of forward gotos, or "" once resolved
+	gotoBlocks     []int    // blockCount at each forward goto
+	gotoLocals     []int    // len(locals) at each forward goto
+	consts         []string // constant names and types
+	constTypes     []int
+	constUntyped   []bool   // true for untyped constants, such as "const n = 5"
+	constValues    []string // exact constant values (see decString)
 	globals        []string // global names and types
 	globalTypes    []int
 	locals         []string // local names and types
 	localTypes     []int
+	localBlocks    []int    // block each local was declared in
 	funcs          []string // function names
 	funcSigIndexes []int    // indexes into funcSigs
 	funcSigs       []int    // for each func: retType N arg1Type ... argNType
+	funcVariadics  []int    // for each func: element type of variadic arg, or 0
+	initFuncs      []string // package init functions, in the order declared
+	funcTypeSigs   []int    // for each func type: retType N arg1Type ... argNType
+	sigStack       []int    // signatures of func types being parsed
 	strs           []string // string constants
+	floats         []string // floating-point constants (IEEE 754 bits)
+	assignNames    []string // variables and value types of the current
+	assignTypes    []int    // assignment (for "a, b = x, y")
+	assertOk       int      // 1 if a type assertion may use the "v, ok" form
+	noBounds       int      // 1 if bounds checks are disabled (-B flag)
+	checkOverflow  int      // 1 if signed integer overflow traps (-V flag)
+	gcStats        int      // 1 to print garbage collector statistics at exit (-G flag)
+	allocProfile   int      // 1 to print an allocation profile at exit (-P flag)
+	allocSites     []string // for each _alloc call site: runtime routine name
+	heapLimit      int      // maximum heap size in bytes (-H flag, in MB)
+
+	// Untyped constant that was the last operand parsed (untypedKind is
+	// typeInt or typeFloat, or 0 if the operand isn't an untyped constant),
+	// and left operands of binary operators saved while parsing the right
+	untypedKind   int
+	untypedNeg    bool
+	untypedDigits string
+	untypedExp    int
+	savedKinds    []int
+	savedValues   []string
+
+	// Generic functions are recorded as tokens when they're declared, and
+	// their bodies are compiled once to check them, with the type parameters
+	// as types of kind kindParam (that code is never run). Each instance is
+	// compiled at the end by replaying the tokens with the type parameters
+	// bound to the instance's type arguments.
+	recording            bool  // true while recording a generic function's tokens
+	recTokens            []int // recorded tokens and their values and positions
+	recInts              []int
+	recStrs              []string
+	recLines             []int
+	recCols              []int
+	replayPos            int      // index of next recorded token to replay
+	replayEnd            int      // end of recorded tokens being replayed, or 0
+	generics             []string // generic function names
+	genericStarts        []int    // range of each one's recorded tokens (-1 if built in)
+	genericEnds          []int
+	genericPackages      []string // package each generic function is in
+	genericImportStarts  []int    // index into genericImports
+	genericImports       []string // imports of each one's file, "" after each
+	genericParams        []int    // index of each one's first type parameter in typeParams
+	genericNumParams     []int
+	typeParams           []string // type parameter names
+	typeParamTypes       []int    // type parameters (their typeElems is the constraint)
+	unionTypes           []int    // terms of union constraints (-typ for ~typ), 0 after each
+	scopeParams          int      // type parameters in scope (index into typeParams)
+	scopeNumParams       int
+	scopeArgs            int      // index into instanceTypeArgs of their types, or -1
+	callTypeArgs         []int    // type arguments of generic calls being parsed
+	instances            []string // generic function instance names
+	instanceGenerics     []int    // index into generics
+	instanceArgs         []int    // index into instanceTypeArgs
+	instanceTypeArgs     []int
+	genericTypes         []string // generic type names, like List in "type List[T any] []T"
+	genericTypeParams    []int    // index of each one's first type parameter in typeParams
+	genericTypeNumParams []int
+	genericTypeUnders    []int // underlying type (using the type parameters)
+	typeInsts            []int // instances of generic types, like List[int]
+	typeInstGenerics     []int // index into genericTypes
+	typeInstArgs         []int // index into instanceTypeArgs
+
+	// Compiled functions in code order, for stack backtraces.
+	codeLabels []string // assembly label
+	codeNames  []string // name as shown in backtraces (such as "main.foo")
 )
 
 const (
-	localSpace int = 64      // max space for locals declared with := (not arguments)
-	heapSize   int = 1048576 // 1MB "heap"
+	localSpace   int = 64      // max space for locals declared with := (not arguments)
+	heapGrowth   int = 1048576 // minimum bytes to grow heap by
+	maxHeap      int = 1024    // default maximum heap size in MB
+	gcMinimum    int = 1048576 // minimum bytes allocated between collections
+	stackReserve int = 262144  // stack kept for argv, environment and overflow handler
+	maxStack     int = 1073741824
+	ioBufSize    int = 65536 // size of stdin and stdout buffers
+	maxProfSites int = 4096  // max entries in allocation profile
 
 	// Types
 	typeVoid     int = 1 // only used as return "type"
@@ -38,31 +143,76 @@ const (
 	typeString   int = 3
 	typeSliceInt int = 4
 	typeSliceStr int = 5
+	typeAny      int = 6 // value (or pointer to string), then type tag
+	typeBool     int = 7
+	typeError    int = 8 // pointer to error string, or 0 if nil
+	typeNil      int = 9 // untyped nil (a zero word)
+	typeSliceAny int = 10
+	typeFloat    int = 11
+	typeSliceFlt int = 12
+	typeInt8     int = 13 // sized integers are stored in 8 bytes too
+	typeInt16    int = 14
+	typeInt32    int = 15
+	typeInt64    int = 16
+	typeUint8    int = 17 // also "byte"
+	typeUint16   int = 18
+	typeUint32   int = 19
+	typeUint64   int = 20
+	typeUint     int = 21
+	typeUintptr  int = 22
+	typeSliceByt int = 23
+
+	// Kinds of type
+	kindBasic   int = 1
+	kindSlice   int = 2
+	kindNamed   int = 3
+	kindPointer int = 4
+	kindTuple   int = 5 // multiple function results
+	kindParam   int = 6 // type parameter
+	kindFunc    int = 7
+
+	// Properties of types (see hasProp)
+	propNumeric    int = 1
+	propInteger    int = 2
+	propOrdered    int = 3
+	propComparable int = 4
 
 	// Keywords
-	tIf      int = 1
-	tElse    int = 2
-	tFor     int = 3
-	tVar     int = 4
-	tConst   int = 5
-	tFunc    int = 6
-	tReturn  int = 7
-	tPackage int = 8
+	tIf          int = 1
+	tElse        int = 2
+	tFor         int = 3
+	tVar         int = 4
+	tConst       int = 5
+	tFunc        int = 6
+	tReturn      int = 7
+	tPackage     int = 8
+	tImport      int = 9
+	tType        int = 10
+	tRange       int = 11
+	tBreak       int = 12
+	tContinue    int = 13
+	tFallthrough int = 14
+	tGoto        int = 15
 
 	// Literals, identifiers, and EOF
-	tIntLit int = 9
-	tStrLit int = 10
-	tIdent  int = 11
-	tEOF    int = 12
-
-	// Two-character tokens
-	tOr         int = 13
-	tAnd        int = 14
-	tEq         int = 15
-	tNotEq      int = 16
-	tLessEq     int = 17
-	tGreaterEq  int = 18
-	tDeclAssign int = 19
+	tIntLit   int = 16
+	tStrLit   int = 17
+	tFloatLit int = 18
+	tCharLit  int = 19
+	tIdent    int = 20
+	tEOF      int = 21
+
+	// Multi-character tokens
+	tOr         int = 22
+	tAnd        int = 23
+	tEq         int = 24
+	tNotEq      int = 25
+	tLessEq     int = 26
+	tGreaterEq  int = 27
+	tDeclAssign int = 28
+	tEllipsis   int = 29
+	tInc        int = 30
+	tDec        int = 31
 
 	// Single-character tokens (these use the ASCII value)
 	tPlus      int = '+'
@@ -73,6 +223,7 @@ const (
 	tComma     int = ','
 	tSemicolon int = ';'
 	tColon     int = ':'
+	tDot       int = '.'
 	tAssign    int = '='
 	tNot       int = '!'
 	tLess      int = '<'
@@ -83,20 +234,53 @@ const (
 	tRBrace    int = '}'
 	tLBracket  int = '['
 	tRBracket  int = ']'
+	tPipe      int = '|'
+	tTilde     int = '~'
 )
 
 // Lexer
 
+// Return the next character from the input files, or -1 after the last
+// one. A missing newline at the end of a file is added.
+func fileChar() int {
+	for inputPos >= len(input) {
+		if c != '\n' && curFile > 0 {
+			return '\n'
+		}
+		if curFile+1 >= len(fileNames) {
+			return -1
+		}
+		curFile = curFile + 1
+		if _stat(fileNames[curFile]) < 0 {
+			log("can't read " + fileNames[curFile] + "\n")
+			exit(1)
+		}
+		input = _readFile(fileNames[curFile])
+		inputPos = 0
+		line = 1
+		col = 0
+	}
+	inputPos = inputPos + 1
+	return int(input[inputPos-1])
+}
+
 func nextChar() {
 	if c == '\n' {
 		line = line + 1
 		col = 0
 	}
-	c = getc()
+	if len(fileNames) == 1 {
+		c = getc()
+	} else {
+		c = fileChar()
+	}
 	col = col + 1
 }
 
 func itoa(n int) string {
+	if n < 0 && -n < 0 {
+		return "-9223372036854775808" // can't negate most negative int
+	}
 	if n < 0 {
 		return "-" + itoa(-n)
 	}
@@ -107,7 +291,11 @@ func itoa(n int) string {
 }
 
 func error(msg string) {
-	log("\n" + itoa(line) + ":" + itoa(col) + ": " + msg + "\n")
+	pos := itoa(line) + ":" + itoa(col)
+	if fileNames[curFile] != "" {
+		pos = fileNames[curFile] + ":" + pos
+	}
+	log("\n" + pos + ": " + msg + "\n")
 	exit(1)
 }
 
@@ -130,6 +318,17 @@ func find(names []string, name string) int {
 	return -1
 }
 
+func findInt(values []int, value int) int {
+	i := 0
+	for i < len(values) {
+		if values[i] == value {
+			return i
+		}
+		i = i + 1
+	}
+	return -1
+}
+
 func expectChar(ch int) {
 	if c != ch {
 		error("expected '" + char(ch) + "' not '" + char(c) + "'")
@@ -147,26 +346,328 @@ func tokenChoice(oneCharToken int, secondCh int, twoCharToken int) {
 	}
 }
 
-func next() {
+// Scan the rest of a floating-point literal after the "." (if any), where
+// digits are the digits of the integer part. Store the literal's digits in
+// tokenStr and its decimal exponent in tokenInt.
+func floatLiteral(digits string) {
+	exp := 0
+	for isDigit(c) || c == '_' {
+		if c != '_' {
+			digits = digits + char(c)
+			exp = exp - 1
+		}
+		nextChar()
+	}
+	if c == 'e' || c == 'E' {
+		nextChar()
+		sign := 1
+		if c == '+' || c == '-' {
+			if c == '-' {
+				sign = -1
+			}
+			nextChar()
+		}
+		if !isDigit(c) {
+			error("exponent has no digits")
+		}
+		n := 0
+		for isDigit(c) || c == '_' {
+			if n > 100000 {
+				error("exponent too large")
+			}
+			if c != '_' {
+				n = n*10 + c - '0'
+			}
+			nextChar()
+		}
+		exp = exp + sign*n
+	}
+	tokenStr = digits
+	tokenInt = exp
+	token = tFloatLit
+}
+
+// Return value of hex digit ch, or -1 if it's not a hex digit.
+func hexValue(ch int) int {
+	if isDigit(ch) {
+		return ch - '0'
+	} else if ch >= 'a' && ch <= 'f' {
+		return ch - 'a' + 10
+	} else if ch >= 'A' && ch <= 'F' {
+		return ch - 'A' + 10
+	}
+	return -1
+}
+
+// Return the UTF-8 encoding of code point r.
+func utf8Encode(r int) string {
+	if r < 128 {
+		return char(r)
+	} else if r < 2048 {
+		return char(192+r/64) + char(128+r%64)
+	} else if r < 65536 {
+		return char(224+r/4096) + char(128+r/64%64) + char(128+r%64)
+	}
+	return char(240+r/262144) + char(128+r/4096%64) + char(128+r/64%64) + char(128+r%64)
+}
+
+// Scan the rest of a UTF-8 encoded character whose first byte is c, and
+// return its code point.
+func utf8Char() int {
+	r := c
+	n := 0 // number of continuation bytes
+	if c >= 248 {
+		error("invalid UTF-8 encoding")
+	} else if c >= 240 {
+		r = c - 240
+		n = 3
+	} else if c >= 224 {
+		r = c - 224
+		n = 2
+	} else if c >= 192 {
+		r = c - 192
+		n = 1
+	} else if c >= 128 {
+		error("invalid UTF-8 encoding")
+	}
+	nextChar()
+	for n > 0 {
+		if c < 128 || c >= 192 {
+			error("invalid UTF-8 encoding")
+		}
+		r = r*64 + c - 128
+		nextChar()
+		n = n - 1
+	}
+	return r
+}
+
+// Scan the next character of an escape, which must be a hex digit.
+func peekHex() int {
+	nextChar()
+	if hexValue(c) < 0 {
+		error("invalid character '" + char(c) + "' in escape")
+	}
+	return c
+}
+
+// Scan a Unicode escape like \u00e9 with n hex digits (after the "u"), and
+// return its code point.
+func unicodeEscape(n int) int {
+	r := 0
+	for n > 0 {
+		r = r*16 + hexValue(peekHex())
+		n = n - 1
+	}
+	if r > 1114111 || r >= 55296 && r < 57344 {
+		error("escape is invalid Unicode code point")
+	}
+	return r
+}
+
+// Return true if c starts a byte escape like \xff or \377 (as opposed to an
+// escape that's a Unicode code point).
+func isByteEscape() bool {
+	return c == 'x' || c >= '0' && c <= '7'
+}
+
+// Scan an escape sequence in a character or string literal delimited by
+// quote (c is the character after the "\"), and return its value. The last
+// character of the escape is left in c.
+func escapeValue(quote int) int {
+	if c == quote || c == '\\' {
+		return c
+	} else if c == 'a' {
+		return 7
+	} else if c == 'b' {
+		return 8
+	} else if c == 'f' {
+		return 12
+	} else if c == 'n' {
+		return '\n'
+	} else if c == 'r' {
+		return '\r'
+	} else if c == 't' {
+		return '\t'
+	} else if c == 'v' {
+		return 11
+	} else if c == 'x' {
+		value := hexValue(peekHex()) * 16
+		return value + hexValue(peekHex())
+	} else if c == 'u' {
+		return unicodeEscape(4)
+	} else if c == 'U' {
+		return unicodeEscape(8)
+	} else if c >= '0' && c <= '7' {
+		// Octal escape like \377 (always three digits)
+		value := c - '0'
+		i := 0
+		for i < 2 {
+			nextChar()
+			if c < '0' || c > '7' {
+				error("invalid character '" + char(c) + "' in escape")
+			}
+			value = value*8 + c - '0'
+			i = i + 1
+		}
+		if value > 255 {
+			error("octal escape value > 255")
+		}
+		return value
+	}
+	error("unexpected escape " + char(quote) + "\\" + char(c) + char(quote))
+	return 0
+}
+
+// Push back identifier name, which was read before the current token, so
+// that the parser sees it again (used to look ahead after an identifier).
+func unreadIdent(name string) {
+	nextToken = token
+	nextTokenInt = tokenInt
+	nextTokenStr = tokenStr
+	token = tIdent
+	tokenStr = name
+}
+
+// Return the decimal digits of the integer literal with the given digits in
+// base, and set tokenInt to its value. The value must fit in 64 bits (values
+// above the largest int are only allowed for uint64).
+func decimalDigits(digits string, base int) string {
+	value := uint64(0)
+	i := 0
+	for i < len(digits) {
+		digit := hexValue(int(digits[i]))
+		if digit >= base && base == 2 {
+			error("invalid digit '" + char(int(digits[i])) + "' in binary literal")
+		} else if digit >= base {
+			error("invalid digit '" + char(int(digits[i])) + "' in octal literal")
+		}
+		limit := 18446744073709551615 - uint64(digit)
+		limit = limit / uint64(base)
+		if value > limit {
+			error("integer literal overflows 64 bits")
+		}
+		value = value*uint64(base) + uint64(digit)
+		i = i + 1
+	}
+	tokenInt = int(value)
+	s := ""
+	for value >= 10 {
+		s = char('0'+int(value%10)) + s
+		value = value / 10
+	}
+	return char('0'+int(value)) + s
+}
+
+// Scan integer or floating-point literal starting with digit c. Integers may
+// have a 0x, 0o, 0b or 0 (octal) prefix, and digits may be separated by "_".
+// The decimal digits are left in tokenStr, as an untyped constant may not
+// fit in tokenInt.
+func numberLiteral() {
+	base := 10
+	tokenStr = ""
+	if c == '0' {
+		nextChar()
+		if c == 'x' || c == 'X' {
+			base = 16
+		} else if c == 'o' || c == 'O' {
+			base = 8
+		} else if c == 'b' || c == 'B' {
+			base = 2
+		} else {
+			tokenStr = "0"
+		}
+		if base != 10 {
+			nextChar()
+		}
+	}
+	last := 0 // previous character, to check placement of "_"
+	for isDigit(c) || c == '_' || base == 16 && hexValue(c) >= 0 {
+		if c == '_' && last == '_' {
+			error("'_' must separate successive digits")
+		}
+		if c != '_' {
+			tokenStr = tokenStr + char(c)
+		}
+		last = c
+		nextChar()
+	}
+	if last == '_' {
+		error("'_' must separate successive digits")
+	}
+	if base == 10 && c == '.' || base == 10 && c == 'e' || base == 10 && c == 'E' {
+		if c == '.' {
+			nextChar()
+		}
+		floatLiteral(tokenStr)
+		return
+	}
+	if tokenStr == "" {
+		error("literal has no digits")
+	}
+	if base == 10 && tokenStr[0] == '0' {
+		base = 8 // legacy octal literal like 0755
+	}
+	tokenStr = decimalDigits(tokenStr, base)
+	token = tIntLit
+}
+
+// Return true if a newline after token t inserts a semicolon.
+// See golang.org/ref/spec#Semicolons
+func insertsSemicolon(t int) bool {
+	return t == tIdent || t == tIntLit || t == tStrLit || t == tFloatLit ||
+		t == tCharLit || t == tBreak || t == tContinue || t == tFallthrough ||
+		t == tReturn || t == tInc || t == tDec || t == tRParen ||
+		t == tRBracket || t == tRBrace
+}
+
+// Skip block comment (c is the "*" after the "/"). Return true if it
+// contains a newline.
+func blockComment() bool {
+	newline := false
+	nextChar()
+	for c >= 0 {
+		if c == '\n' {
+			newline = true
+		}
+		if c == '*' {
+			nextChar()
+			if c == '/' {
+				nextChar()
+				return newline
+			}
+		} else {
+			nextChar()
+		}
+	}
+	error("comment not terminated")
+	return false
+}
+
+// Scan the next token from the input.
+func scan() {
 	// Skip whitespace and comments, and look for / operator
 	for c == '/' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
 		if c == '/' {
 			nextChar()
-			if c != '/' {
+			if c == '*' {
+				// Block comment acts like a newline if it contains any
+				if blockComment() && insertsSemicolon(token) {
+					token = tSemicolon
+					return
+				}
+			} else if c == '/' {
+				// Line comment, skip till end of line
+				for c >= 0 && c != '\n' {
+					nextChar()
+				}
+			} else {
 				token = tDivide
 				return
 			}
-			nextChar()
-			// Comment, skip till end of line
-			for c >= 0 && c != '\n' {
-				nextChar()
-			}
 		} else if c == '\n' {
 			nextChar()
-			// Semicolon insertion: golang.org/ref/spec#Semicolons
-			if token == tIdent || token == tIntLit || token == tStrLit ||
-				token == tReturn || token == tRParen ||
-				token == tRBracket || token == tRBrace {
+			if insertsSemicolon(token) {
 				token = tSemicolon
 				return
 			}
@@ -175,20 +676,18 @@ func next() {
 		}
 	}
 	if c < 0 {
-		// End of file
+		// End of file (which also inserts a semicolon)
+		if insertsSemicolon(token) {
+			token = tSemicolon
+			return
+		}
 		token = tEOF
 		return
 	}
 
-	// Integer literal
+	// Integer or floating-point literal
 	if isDigit(c) {
-		tokenInt = c - '0'
-		nextChar()
-		for isDigit(c) {
-			tokenInt = tokenInt*10 + c - '0'
-			nextChar()
-		}
-		token = tIntLit
+		numberLiteral()
 		return
 	}
 
@@ -199,28 +698,15 @@ func next() {
 			error("newline not allowed in character literal")
 		}
 		if c == '\\' {
-			// Escape character
 			nextChar()
-			if c == '\'' {
-				tokenInt = '\''
-			} else if c == '\\' {
-				tokenInt = '\\'
-			} else if c == 't' {
-				tokenInt = '\t'
-			} else if c == 'r' {
-				tokenInt = '\r'
-			} else if c == 'n' {
-				tokenInt = '\n'
-			} else {
-				error("unexpected escape '\\" + char(c) + "'")
-			}
+			tokenInt = escapeValue('\'')
 			nextChar()
 		} else {
-			tokenInt = c
-			nextChar()
+			tokenInt = utf8Char()
 		}
 		expectChar('\'')
-		token = tIntLit
+		tokenStr = itoa(tokenInt)
+		token = tCharLit
 		return
 	}
 
@@ -233,23 +719,15 @@ func next() {
 				error("newline not allowed in string")
 			}
 			if c == '\\' {
-				// Escape character
 				nextChar()
-				if c == '"' {
-					c = '"'
-				} else if c == '\\' {
-					c = '\\'
-				} else if c == 't' {
-					c = '\t'
-				} else if c == 'r' {
-					c = '\r'
-				} else if c == 'n' {
-					c = '\n'
+				if isByteEscape() {
+					tokenStr = tokenStr + char(escapeValue('"'))
 				} else {
-					error("unexpected escape \"\\" + char(c) + "\"")
+					tokenStr = tokenStr + utf8Encode(escapeValue('"'))
 				}
+			} else {
+				tokenStr = tokenStr + char(c)
 			}
-			tokenStr = tokenStr + char(c)
 			nextChar()
 		}
 		expectChar('"')
@@ -257,6 +735,21 @@ func next() {
 		return
 	}
 
+	// Raw string literal (carriage returns are discarded)
+	if c == '`' {
+		nextChar()
+		tokenStr = ""
+		for c >= 0 && c != '`' {
+			if c != '\r' {
+				tokenStr = tokenStr + char(c)
+			}
+			nextChar()
+		}
+		expectChar('`')
+		token = tStrLit
+		return
+	}
+
 	// Keyword or identifier
 	if isAlpha(c) || c == '_' {
 		tokenStr = char(c)
@@ -266,7 +759,7 @@ func next() {
 			nextChar()
 		}
 		index := find(tokens, tokenStr)
-		if index >= tIf && index <= tPackage {
+		if index >= tIf && index <= tGoto {
 			// Keyword
 			token = index
 		} else {
@@ -277,16 +770,22 @@ func next() {
 	}
 
 	// Single-character tokens (token is ASCII value)
-	if c == '+' || c == '-' || c == '*' || c == '%' || c == ';' ||
+	if c == '*' || c == '%' || c == ';' ||
 		c == ',' || c == '(' || c == ')' || c == '{' || c == '}' ||
-		c == '[' || c == ']' {
+		c == '[' || c == ']' || c == '~' {
 		token = c
 		nextChar()
 		return
 	}
 
 	// One or two-character tokens
-	if c == '=' {
+	if c == '+' {
+		tokenChoice(tPlus, '+', tInc)
+		return
+	} else if c == '-' {
+		tokenChoice(tMinus, '-', tDec)
+		return
+	} else if c == '=' {
 		tokenChoice(tAssign, '=', tEq)
 		return
 	} else if c == '<' {
@@ -305,9 +804,7 @@ func next() {
 
 	// Two-character tokens
 	if c == '|' {
-		nextChar()
-		expectChar('|')
-		token = tOr
+		tokenChoice(tPipe, '|', tOr)
 		return
 	} else if c == '&' {
 		nextChar()
@@ -316,13 +813,71 @@ func next() {
 		return
 	}
 
+	// Dot, ellipsis, or floating-point literal like ".5"
+	if c == '.' {
+		nextChar()
+		if c == '.' {
+			nextChar()
+			expectChar('.')
+			token = tEllipsis
+		} else if isDigit(c) {
+			floatLiteral("")
+		} else {
+			token = tDot
+		}
+		return
+	}
+
 	error("unexpected '" + char(c) + "'")
 }
 
+// Record the current token as part of a generic function.
+func recordToken() {
+	recTokens = append(recTokens, token)
+	recInts = append(recInts, tokenInt)
+	recStrs = append(recStrs, tokenStr)
+	recLines = append(recLines, line)
+	recCols = append(recCols, col)
+}
+
+// Read the next token from the recorded ones being replayed.
+func replayToken() {
+	if replayPos >= replayEnd {
+		token = tEOF
+		return
+	}
+	token = recTokens[replayPos]
+	tokenInt = recInts[replayPos]
+	tokenStr = recStrs[replayPos]
+	line = recLines[replayPos]
+	col = recCols[replayPos]
+	replayPos = replayPos + 1
+}
+
+func next() {
+	if nextToken != 0 {
+		// Token pushed back by unreadIdent
+		token = nextToken
+		tokenInt = nextTokenInt
+		tokenStr = nextTokenStr
+		nextToken = 0
+		return
+	}
+	if replayEnd > 0 {
+		replayToken()
+		return
+	}
+	scan()
+	if recording {
+		recordToken()
+	}
+}
+
 // Escape given string; use "delim" as quote character.
 func escape(s string, delim string) string {
 	i := 0
 	quoted := delim
+	hex := "0123456789abcdef"
 	for i < len(s) {
 		if s[i] == '"' {
 			quoted = quoted + "\\\""
@@ -336,6 +891,8 @@ func escape(s string, delim string) string {
 			quoted = quoted + "\\n"
 		} else if s[i] == '`' {
 			quoted = quoted + "\\`"
+		} else if s[i] < ' ' || s[i] == 127 {
+			quoted = quoted + "\\x" + char(int(hex[s[i]/16])) + char(int(hex[s[i]%16]))
 		} else {
 			quoted = quoted + char(int(s[i]))
 		}
@@ -351,226 +908,1548 @@ func tokenName(t int) string {
 	return tokens[t]
 }
 
-// Code generator functions
+// Constant arithmetic. Untyped constants are exact decimals: a sign, digits,
+// and a power of ten to multiply the digits by. Digits are a "nat", a string
+// of decimal digits without leading zeros.
 
-func genProgramStart() {
-	print("global _start\n")
-	print("section .text\n")
-	print("\n")
+// Return bytes start up to (but not including) end of s.
+func substr(s string, start int, end int) string {
+	t := ""
+	for start < end {
+		t = t + char(int(s[start]))
+		start = start + 1
+	}
+	return t
+}
 
-	// Initialize and call main.
-	print("_start:\n")
-	print("xor rax, rax\n") // ensure heap is zeroed
-	print("mov rdi, _heap\n")
-	print("mov rcx, " + itoa(heapSize/8) + "\n")
-	print("rep stosq\n")
-	print("mov rax, _heap\n")
-	print("mov [_heapPtr], rax\n")
-	print("call main\n")
-	print("mov rax, 60\n") // system call for "exit"
-	print("mov rdi, 0\n")  // exit code 0
-	print("syscall\n")
-	print("\n")
+// Return digits s without leading zeros.
+func natTrim(s string) string {
+	t := ""
+	i := 0
+	for i < len(s) {
+		if len(t) > 0 || s[i] != '0' {
+			t = t + char(int(s[i]))
+		}
+		i = i + 1
+	}
+	if t == "" {
+		return "0"
+	}
+	return t
+}
 
-	// Write a string to stdout.
-	print("print:\n")
-	print("push rbp\n") // rbp ret addr len
-	print("mov rbp, rsp\n")
-	print("mov rax, 1\n")        // system call for "write"
-	print("mov rdi, 1\n")        // file handle 1 is stdout
-	print("mov rsi, [rbp+16]\n") // address
-	print("mov rdx, [rbp+24]\n") // length
-	print("syscall\n")
-	print("pop rbp\n")
-	print("ret 16\n")
-	print("\n")
+// Compare nats a and b, returning -1, 0 or 1.
+func natCmp(a string, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	i := 0
+	for i < len(a) {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+		i = i + 1
+	}
+	return 0
+}
 
-	// Write a string to stderr.
-	print("log:\n")
-	print("push rbp\n") // rbp ret addr len
-	print("mov rbp, rsp\n")
-	print("mov rax, 1\n")        // system call for "write"
-	print("mov rdi, 2\n")        // file handle 2 is stderr
-	print("mov rsi, [rbp+16]\n") // address
-	print("mov rdx, [rbp+24]\n") // length
-	print("syscall\n")
-	print("pop rbp\n")
-	print("ret 16\n")
-	print("\n")
+// Return nat s as an int (it must have at most 18 digits). Arithmetic on
+// small nats uses ints, as the compiler's heap is too small for much string
+// building.
+func natInt(s string) int {
+	n := 0
+	i := 0
+	for i < len(s) {
+		n = n*10 + int(s[i]) - '0'
+		i = i + 1
+	}
+	return n
+}
 
-	// Read a single byte from stdin, or return -1 on EOF.
-	print("getc:\n")
-	print("push qword 0\n")
-	print("mov rax, 0\n")   // system call for "read"
-	print("mov rdi, 0\n")   // file handle 0 is stdin
-	print("mov rsi, rsp\n") // address
-	print("mov rdx, 1\n")   // length
-	print("syscall\n")
-	print("cmp rax, 1\n")
-	print("je _getc1\n")
-	print("mov qword [rsp], -1\n")
-	print("_getc1:\n")
-	print("pop rax\n")
-	print("ret\n")
-	print("\n")
+func natAdd(a string, b string) string {
+	if len(a) < 18 && len(b) < 18 {
+		return itoa(natInt(a) + natInt(b))
+	}
+	s := ""
+	carry := 0
+	i := len(a) - 1
+	j := len(b) - 1
+	for i >= 0 || j >= 0 || carry > 0 {
+		d := carry
+		if i >= 0 {
+			d = d + int(a[i]) - '0'
+		}
+		if j >= 0 {
+			d = d + int(b[j]) - '0'
+		}
+		s = char('0'+d%10) + s
+		carry = d / 10
+		i = i - 1
+		j = j - 1
+	}
+	return natTrim(s)
+}
 
-	// Like os.Exit().
-	print("exit:\n")
-	print("mov rdi, [rsp+8]\n") // code
-	print("mov rax, 60\n")      // system call for "exit"
-	print("syscall\n")
-	print("\n")
+// Return a - b, where a >= b.
+func natSub(a string, b string) string {
+	if len(a) < 19 {
+		return itoa(natInt(a) - natInt(b))
+	}
+	s := ""
+	borrow := 0
+	i := len(a) - 1
+	j := len(b) - 1
+	for i >= 0 {
+		d := int(a[i]) - '0' - borrow
+		if j >= 0 {
+			d = d - int(b[j]) + '0'
+		}
+		borrow = 0
+		if d < 0 {
+			d = d + 10
+			borrow = 1
+		}
+		s = char('0'+d) + s
+		i = i - 1
+		j = j - 1
+	}
+	return natTrim(s)
+}
 
-	// No-op int() for use in escape(), to satisfy Go's type checker.
-	print("int:\n")
-	print("mov rax, [rsp+8]\n") // value
-	print("ret 8\n")
-	print("\n")
+// Return a * 10**n.
+func natShift(a string, n int) string {
+	if a == "0" {
+		return a
+	}
+	for n > 0 {
+		a = a + "0"
+		n = n - 1
+	}
+	return a
+}
 
-	// Return concatenation of two strings.
-	print("_strAdd:\n")
-	print("push rbp\n") // rbp ret addr1 len1 addr0 len0
-	print("mov rbp, rsp\n")
-	// Allocate len0+len1 bytes
-	print("mov rax, [rbp+24]\n") // len1
-	print("add rax, [rbp+40]\n") // len1 + len0
-	print("push rax\n")
-	print("call _alloc\n")
-	// Move len0 bytes from addr0 to addrNew
-	print("mov rsi, [rbp+32]\n")
+// Return a * n, where n < 10**9.
+func natMulInt(a string, n int) string {
+	if len(a) < 10 {
+		return itoa(natInt(a) * n)
+	}
+	s := ""
+	carry := 0
+	i := len(a) - 1
+	for i >= 0 || carry > 0 {
+		d := carry
+		if i >= 0 {
+			d = d + n*int(a[i]) - n*'0'
+		}
+		s = char('0'+d%10) + s
+		carry = d / 10
+		i = i - 1
+	}
+	return natTrim(s)
+}
+
+func natMul(a string, b string) string {
+	if len(b) < 10 {
+		return natMulInt(a, natInt(b))
+	} else if len(a) < 10 {
+		return natMulInt(b, natInt(a))
+	}
+	p := "0"
+	i := 0
+	for i < len(b) {
+		p = natAdd(natShift(p, 1), natMulInt(a, int(b[i])-'0'))
+		i = i + 1
+	}
+	return p
+}
+
+// Return a / n and a % n, where 0 < n < 10**17.
+func natDivInt(a string, n int) (string, string) {
+	q := ""
+	r := 0
+	i := 0
+	for i < len(a) {
+		r = r*10 + int(a[i]) - '0'
+		q = q + char('0'+r/n)
+		r = r % n
+		i = i + 1
+	}
+	return natTrim(q), itoa(r)
+}
+
+// Return a / b and a % b (b must not be zero).
+func natDivMod(a string, b string) (string, string) {
+	if len(a) < 19 {
+		return itoa(natInt(a) / natInt(b)), itoa(natInt(a) % natInt(b))
+	} else if len(b) < 17 {
+		return natDivInt(a, natInt(b))
+	}
+	q := ""
+	r := "0"
+	i := 0
+	for i < len(a) {
+		r = natAdd(natShift(r, 1), char(int(a[i])))
+		d := 0
+		for natCmp(r, b) >= 0 {
+			r = natSub(r, b)
+			d = d + 1
+		}
+		q = q + char('0'+d)
+		i = i + 1
+	}
+	return natTrim(q), r
+}
+
+// Return decimal neg, digits, exp normalized: without trailing zeros after
+// the decimal point, and with zero positive.
+func decNorm(neg bool, digits string, exp int) (bool, string, int) {
+	digits = natTrim(digits)
+	if digits == "0" {
+		return false, digits, 0
+	}
+	for exp < 0 && digits[len(digits)-1] == '0' {
+		digits = substr(digits, 0, len(digits)-1)
+		exp = exp + 1
+	}
+	return neg, digits, exp
+}
+
+func decAdd(aNeg bool, a string, aExp int, bNeg bool, b string, bExp int) (bool, string, int) {
+	for aExp > bExp {
+		a = natShift(a, 1)
+		aExp = aExp - 1
+	}
+	for bExp > aExp {
+		b = natShift(b, 1)
+		bExp = bExp - 1
+	}
+	if aNeg == bNeg {
+		return decNorm(aNeg, natAdd(a, b), aExp)
+	} else if natCmp(a, b) >= 0 {
+		return decNorm(aNeg, natSub(a, b), aExp)
+	}
+	return decNorm(bNeg, natSub(b, a), aExp)
+}
+
+func decMul(aNeg bool, a string, aExp int, bNeg bool, b string, bExp int) (bool, string, int) {
+	return decNorm(aNeg != bNeg, natMul(a, b), aExp+bExp)
+}
+
+// Compare decimals a and b, returning -1, 0 or 1.
+func decCmp(aNeg bool, a string, aExp int, bNeg bool, b string, bExp int) int {
+	neg, diff, _ := decAdd(aNeg, a, aExp, !bNeg, b, bExp)
+	if diff == "0" {
+		return 0
+	} else if neg {
+		return -1
+	}
+	return 1
+}
+
+// Return a / b to about 40 significant digits.
+func decQuo(aNeg bool, a string, aExp int, bNeg bool, b string, bExp int) (bool, string, int) {
+	if b == "0" {
+		error("division by zero")
+	}
+	shift := 40 + len(b) - len(a)
+	if shift < 0 {
+		shift = 0
+	}
+	q, _ := natDivMod(natShift(a, shift), b)
+	return decNorm(aNeg != bNeg, q, aExp-bExp-shift)
+}
+
+// Return integers a / b, or a % b if mod is true (truncating towards zero).
+func decIntDiv(aNeg bool, a string, bNeg bool, b string, mod bool) (bool, string, int) {
+	if b == "0" {
+		error("division by zero")
+	}
+	q, r := natDivMod(a, b)
+	if mod {
+		return decNorm(aNeg, r, 0)
+	}
+	return decNorm(aNeg != bNeg, q, 0)
+}
+
+// Convert decimal to an int, returning false if it's not an integer or
+// doesn't fit.
+func decToInt(neg bool, digits string, exp int) (int, bool) {
+	if exp < 0 || len(digits)+exp > 19 {
+		return 0, false
+	}
+	digits = natShift(digits, exp)
+	limit := "9223372036854775807"
+	if neg {
+		limit = "9223372036854775808"
+	}
+	if natCmp(digits, limit) > 0 {
+		return 0, false
+	}
+	n := 0 // accumulate negative so that the most negative int fits
+	i := 0
+	for i < len(digits) {
+		n = n*10 - int(digits[i]) + '0'
+		i = i + 1
+	}
+	if !neg {
+		n = -n
+	}
+	return n, true
+}
+
+// Return decimal as a string such as "-12.5", the form used for errors and
+// in constValues.
+func decString(neg bool, digits string, exp int) string {
+	s := natShift(digits, exp)
+	if exp < 0 {
+		for len(s) <= -exp {
+			s = "0" + s
+		}
+		s = substr(s, 0, len(s)+exp) + "." + substr(s, len(s)+exp, len(s))
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// Parse a decimal string produced by decString.
+func decParse(s string) (bool, string, int) {
+	neg := false
+	digits := ""
+	exp := 0
+	i := 0
+	for i < len(s) {
+		if s[i] == '-' {
+			neg = true
+		} else if s[i] == '.' {
+			exp = i + 1 - len(s)
+		} else {
+			digits = digits + char(int(s[i]))
+		}
+		i = i + 1
+	}
+	return decNorm(neg, digits, exp)
+}
+
+// Return 2 to the power k as a nat.
+func natPow2(k int) string {
+	p := "1"
+	for k >= 29 {
+		p = natMulInt(p, 536870912) // 2**29
+		k = k - 29
+	}
+	n := 1
+	for k > 0 {
+		n = n * 2
+		k = k - 1
+	}
+	return natMulInt(p, n)
+}
+
+// Scale num and den by powers of two so that 2**52 <= num/den < 2**53 (or
+// less for subnormal numbers), returning them and the power of two e such
+// that the original num/den is the new num/den * 2**e.
+func floatScale(num string, den string) (string, string, int) {
+	e := len(num) - len(den)
+	e = e*3321928/1000000 - 52 // estimate using log2(10)
+	if e > 0 {
+		den = natMul(den, natPow2(e))
+	} else {
+		num = natMul(num, natPow2(-e))
+	}
+	den52 := natMul(den, natPow2(52))
+	for natCmp(num, natMulInt(den52, 2)) >= 0 {
+		den = natMulInt(den, 2)
+		den52 = natMulInt(den52, 2)
+		e = e + 1
+	}
+	for natCmp(num, den52) < 0 && e > -1074 {
+		num = natMulInt(num, 2)
+		e = e - 1
+	}
+	for e < -1074 {
+		den = natMulInt(den, 2)
+		e = e + 1
+	}
+	return num, den, e
+}
+
+// Return the IEEE 754 bits of num/den * 2**e rounded to the nearest
+// float64, where num/den is scaled as by floatScale.
+func floatRound(num string, den string, e int) int {
+	q, r := natDivMod(num, den)
+	cmp := natCmp(natMulInt(r, 2), den)
+	odd := int(q[len(q)-1]) % 2 // 1 if q is odd
+	if cmp > 0 || cmp == 0 && odd == 1 {
+		q = natAdd(q, "1") // round half to even
+	}
+	n, _ := decToInt(false, q, 0)
+	if n == 9007199254740992 {
+		n = 4503599627370496
+		e = e + 1
+	}
+	if e > 971 {
+		return -1 // overflow (not a valid result, as the sign bit is set)
+	}
+	if n < 4503599627370496 {
+		return n // subnormal
+	}
+	e = e + 1075
+	return e*4503599627370496 + n - 4503599627370496
+}
+
+// Return the IEEE 754 bits of the float64 nearest to the given decimal.
+func floatBits(neg bool, digits string, exp int) int {
+	if digits == "0" || len(digits)+exp < -400 {
+		return 0
+	}
+	bits := -1
+	if len(digits)+exp <= 310 {
+		num := digits
+		den := "1"
+		if exp >= 0 {
+			num = natShift(num, exp)
+		} else {
+			den = natShift(den, -exp)
+		}
+		num, den, e := floatScale(num, den)
+		bits = floatRound(num, den, e)
+	}
+	if bits < 0 {
+		error("constant " + decString(neg, digits, exp) + " overflows float64")
+	}
+	if neg {
+		bits = bits - 9223372036854775807 - 1 // set sign bit
+	}
+	return bits
+}
+
+// Code generator functions
+
+// Generate a call to _alloc from runtime routine name, recording the call
+// site for the allocation profile (-P flag).
+func genAllocCall(name string) {
+	print("call _alloc\n")
+	if allocProfile == 1 {
+		print("_allocSite" + itoa(len(allocSites)) + ":\n")
+		allocSites = append(allocSites, name)
+	}
+}
+
+// Generate the file builtins, used to read the source files named on the
+// command line and by lib/fileio. Errors are returned as the negated errno.
+func genFileIO() {
+	// Return a NUL-terminated copy of the string with address rax and
+	// length rbx in rax.
+	print("_cString:\n")
+	print("push rax\n")
+	print("push rbx\n")
+	print("lea rcx, [rbx+1]\n")
+	print("push rcx\n")
+	genAllocCall("_cString") // heap is zeroed, so it's NUL-terminated
+	print("pop rcx\n")
+	print("pop rsi\n")
 	print("mov rdi, rax\n")
-	print("mov rcx, [rbp+40]\n")
 	print("rep movsb\n")
-	// Move len1 bytes from addr1 to addrNew+len0
-	print("mov rsi, [rbp+16]\n")
+	print("ret\n")
+	print("\n")
+
+	// Open the named file with the given flags and permissions, and return
+	// its file descriptor.
+	print("_openFile:\n")
+	print("mov rax, [rsp+24]\n") // ret perm flag addr len
+	print("mov rbx, [rsp+32]\n")
+	print("call _cString\n")
 	print("mov rdi, rax\n")
-	print("add rdi, [rbp+40]\n")
-	print("mov rcx, [rbp+24]\n")
-	print("rep movsb\n")
-	// Return addrNew len0+len1 (addrNew already in rax)
-	print("mov rbx, [rbp+24]\n")
-	print("add rbx, [rbp+40]\n")
-	print("pop rbp\n")
+	print("mov rsi, [rsp+16]\n")
+	print("mov rdx, [rsp+8]\n")
+	print("mov rax, 2\n") // system call for "open"
+	print("syscall\n")
 	print("ret 32\n")
 	print("\n")
 
-	// Return true if strings are equal.
-	print("_strEq:\n")
-	print("push rbp\n") // rbp ret addr1 len1 addr0 len0
+	// Read up to len(buf) bytes from a file into buf and return the number
+	// of bytes read (0 at end of file).
+	print("_read:\n")
+	print("xor rax, rax\n")      // system call for "read"
+	print("mov rdi, [rsp+32]\n") // ret addr len cap fd
+	print("mov rsi, [rsp+8]\n")
+	print("mov rdx, [rsp+16]\n")
+	print("syscall\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Write all of a string to a file and return its length.
+	print("_write:\n")
+	print("call _flush\n") // keep output to stdout in order
+	print("push rbp\n")    // rbp ret addr len fd
 	print("mov rbp, rsp\n")
-	print("mov rcx, [rbp+40]\n")
-	print("cmp rcx, [rbp+24]\n")
-	print("jne _strEqNotEqual\n")
 	print("mov rsi, [rbp+16]\n")
+	print("mov rdx, [rbp+24]\n")
+	print("_write1:\n")
+	print("test rdx, rdx\n")
+	print("jz _write2\n")
+	print("mov rax, 1\n") // system call for "write"
 	print("mov rdi, [rbp+32]\n")
-	print("rep cmpsb\n")
-	print("jne _strEqNotEqual\n")
-	print("mov rax, 1\n")
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("js _write3\n")
+	print("add rsi, rax\n")
+	print("sub rdx, rax\n")
+	print("jmp _write1\n")
+	print("_write2:\n")
+	print("mov rax, [rbp+24]\n")
+	print("_write3:\n")
 	print("pop rbp\n")
-	print("ret 32\n")
-	// Return addrNew len0+len1 (addrNew already in rax)
-	print("_strEqNotEqual:\n")
-	print("xor rax, rax\n")
+	print("ret 24\n")
+	print("\n")
+
+	// Close a file, returning 0 on success.
+	print("_close:\n")
+	print("mov rax, 3\n")       // system call for "close"
+	print("mov rdi, [rsp+8]\n") // fd
+	print("syscall\n")
+	print("ret 8\n")
+	print("\n")
+
+	// Return the size of the named file in bytes.
+	print("_stat:\n")
+	print("push rbp\n") // rbp ret addr len
+	print("mov rbp, rsp\n")
+	print("sub rsp, 144\n") // struct stat
+	print("mov rax, [rbp+16]\n")
+	print("mov rbx, [rbp+24]\n")
+	print("call _cString\n")
+	print("mov rdi, rax\n")
+	print("mov rsi, rsp\n")
+	print("mov rax, 4\n") // system call for "stat"
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("js _stat1\n")
+	print("mov rax, [rsp+48]\n") // st_size
+	print("_stat1:\n")
+	print("mov rsp, rbp\n")
 	print("pop rbp\n")
-	print("ret 32\n")
+	print("ret 16\n")
 	print("\n")
 
-	// Return new 1-byte string from integer character.
-	print("char:\n")
-	print("push rbp\n") // rbp ret ch
+	// Return the contents of the named file, or "" if it can't be read.
+	// Reads until end of file, as the size from fstat is only a hint (it's
+	// 0 for pipes and files in /proc).
+	print("_readFile:\n")
+	print("push rbp\n") // rbp ret addr len
 	print("mov rbp, rsp\n")
-	// Allocate 1 byte
-	print("push 1\n")
-	print("call _alloc\n")
-	// Move byte to destination
-	print("mov rbx, [rbp+16]\n")
-	print("mov [rax], bl\n")
-	// Return addrNew 1 (addrNew already in rax)
-	print("mov rbx, 1\n")
+	print("sub rsp, 176\n") // fd, data, length read, capacity, struct stat
+	print("mov qword [rbp-16], 0\n")
+	print("mov qword [rbp-24], 0\n")
+	print("mov rax, [rbp+16]\n")
+	print("mov rbx, [rbp+24]\n")
+	print("call _cString\n")
+	print("mov rdi, rax\n")
+	print("xor rsi, rsi\n") // O_RDONLY
+	print("mov rax, 2\n")   // system call for "open"
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("js _readFile6\n")
+	print("mov [rbp-8], rax\n")
+	print("mov rdi, rax\n")
+	print("mov rsi, rsp\n")
+	print("mov rax, 5\n") // system call for "fstat"
+	print("syscall\n")
+	print("mov rbx, 512\n") // minimum capacity
+	print("test rax, rax\n")
+	print("js _readFile1\n")
+	print("mov rax, [rsp+48]\n") // st_size, plus 1 to read end of file
+	print("inc rax\n")
+	print("cmp rax, rbx\n")
+	print("jbe _readFile1\n")
+	print("mov rbx, rax\n")
+	// Grow buffer to rbx bytes and copy what's been read so far
+	print("_readFile1:\n")
+	print("mov [rbp-32], rbx\n")
+	print("push rbx\n")
+	genAllocCall("_readFile")
+	print("mov rdi, rax\n")
+	print("mov rsi, [rbp-16]\n")
+	print("mov rcx, [rbp-24]\n")
+	print("rep movsb\n")
+	print("mov [rbp-16], rax\n")
+	print("_readFile2:\n")
+	print("mov rdx, [rbp-32]\n")
+	print("sub rdx, [rbp-24]\n")
+	print("jnz _readFile3\n")
+	print("mov rbx, [rbp-32]\n") // buffer is full, double it
+	print("add rbx, rbx\n")
+	print("jmp _readFile1\n")
+	print("_readFile3:\n")
+	print("xor rax, rax\n") // system call for "read"
+	print("mov rdi, [rbp-8]\n")
+	print("mov rsi, [rbp-16]\n")
+	print("add rsi, [rbp-24]\n")
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("jle _readFile4\n")
+	print("add [rbp-24], rax\n")
+	print("jmp _readFile2\n")
+	print("_readFile4:\n")
+	print("jz _readFile5\n") // end of file
+	print("mov qword [rbp-16], 0\n")
+	print("mov qword [rbp-24], 0\n")
+	print("_readFile5:\n")
+	print("mov rax, 3\n") // system call for "close"
+	print("mov rdi, [rbp-8]\n")
+	print("syscall\n")
+	// Return data length
+	print("_readFile6:\n")
+	print("mov rax, [rbp-16]\n")
+	print("mov rbx, [rbp-24]\n")
+	print("mov rsp, rbp\n")
 	print("pop rbp\n")
-	print("ret 8\n")
+	print("ret 16\n")
+	print("\n")
+}
+
+// Generate bounds-check panic routine label, called with the index in r8,
+// the length or capacity in r9 and the source position in r10 and r11. The
+// message is str1 + index + str2 + length (str1 and str2 have lengths len1
+// and len2).
+func genPanicBounds(label string, str1 string, len1 int, str2 string, len2 int) {
+	print(label + ":\n")
+	print("mov r15, [rsp]\n") // for backtrace (see _panic)
+	print("dec r15\n")
+	print("mov r12, rbp\n")
+	print("push r11\n") // syscalls clobber r11
+	print("push r10\n")
+	print("push r9\n")
+	print("push r8\n")
+	print("push qword 22\n") // len("panic: runtime error: ")
+	print("push _strPanic\n")
+	print("call log\n")
+	print("push qword " + itoa(len1) + "\n")
+	print("push " + str1 + "\n")
+	print("call log\n")
+	print("pop rax\n") // index
+	print("call _logInt\n")
+	print("push qword " + itoa(len2) + "\n")
+	print("push " + str2 + "\n")
+	print("call log\n")
+	print("pop rax\n") // length or capacity
+	print("call _logInt\n")
+	print("jmp _panicEnd\n")
 	print("\n")
+}
+
+// Generate code to log string str (of length n) followed by value (an
+// operand of "push") as a decimal integer.
+func genLogStat(str string, n int, value string) {
+	print("push " + value + "\n")
+	print("push qword " + itoa(n) + "\n")
+	print("push " + str + "\n")
+	print("call log\n")
+	print("pop rax\n")
+	print("call _logInt\n")
+}
 
-	// Simple bump allocator (with no GC!). Takes allocation size in bytes,
-	// returns pointer to allocated memory.
+// Generate the allocator. The heap is a sequence of blocks from _heapBase
+// up to _heapPtr, each with an 8-byte header holding the block size (a
+// multiple of 8, including the header), with bit 0 set if it's marked and
+// bit 1 set if it's free. Free blocks are linked through their first data
+// word. The bit for each block's header is set in the _heapBits bitmap so
+// the collector can find the block an address points into. The heap's
+// memory (up to _heapEnd) is obtained with the brk system call as needed.
+func genAlloc() {
+	// Allocate and zero the given number of bytes and return a pointer
+	// to them in rax. Preserves all other registers (they're pushed so the
+	// collector sees any pointers in them). Takes the first big enough free
+	// block, otherwise takes memory from the end of the heap, growing it if
+	// necessary. It collects garbage first if the bytes allocated since the
+	// last collection reach _gcNext, or if the heap can't grow.
 	print("_alloc:\n")
 	print("push rbp\n") // rbp ret size
 	print("mov rbp, rsp\n")
-	print("mov rax, [_heapPtr]\n")
-	print("mov rbx, [rbp+16]\n")
-	print("add rbx, [_heapPtr]\n")
-	print("cmp rbx, _heapEnd\n")
-	print("jg _outOfMem\n")
-	print("mov [_heapPtr], rbx\n")
+	print("push rbx\n")
+	print("push rcx\n")
+	print("push rdx\n")
+	print("push rsi\n")
+	print("push rdi\n")
+	print("push r8\n")
+	print("push r9\n")
+	print("push r10\n")
+	print("push r11\n")
+	print("push r12\n")
+	print("push r13\n")
+	print("push r14\n")
+	print("push r15\n")
+	if allocProfile == 1 {
+		print("call _allocProfile\n")
+	}
+	print("mov rbx, [rbp+16]\n") // block size is size rounded up plus header
+	print("add rbx, 15\n")
+	print("and rbx, -8\n")
+	print("cmp rbx, 16\n") // room for free list link
+	print("jae _alloc1\n")
+	print("mov rbx, 16\n")
+	print("_alloc1:\n")
+	print("xor r12, r12\n") // 1 after collecting garbage
+	print("_alloc2:\n")
+	print("mov r8, _freeList\n") // address of link to current block
+	print("_alloc3:\n")
+	print("mov r9, [r8]\n")
+	print("test r9, r9\n")
+	print("jz _alloc6\n")
+	print("mov rcx, [r9]\n")
+	print("and rcx, -4\n") // size of free block
+	print("cmp rcx, rbx\n")
+	print("jae _alloc4\n")
+	print("lea r8, [r9+8]\n")
+	print("jmp _alloc3\n")
+	print("_alloc4:\n")
+	print("mov rdx, [r9+8]\n") // next free block
+	print("sub rcx, rbx\n")
+	print("cmp rcx, 16\n")
+	print("jb _alloc5\n")
+	// Split block, leaving the remainder on the free list
+	print("lea r10, [r9+rbx]\n")
+	print("or rcx, 2\n")
+	print("mov [r10], rcx\n")
+	print("mov [r10+8], rdx\n")
+	print("mov [r8], r10\n")
+	print("sub r10, [_heapBase]\n")
+	print("shr r10, 3\n")
+	print("mov r11, [_heapBits]\n")
+	print("bts [r11], r10\n")
+	print("mov [r9], rbx\n")
+	print("jmp _alloc7\n")
+	// Use whole block
+	print("_alloc5:\n")
+	print("mov [r8], rdx\n")
+	print("mov rbx, [r9]\n")
+	print("and rbx, -4\n")
+	print("mov [r9], rbx\n")
+	print("jmp _alloc7\n")
+	// Take block from the end of the heap
+	print("_alloc6:\n")
+	print("test r12, r12\n")
+	print("jnz _alloc8\n")
+	print("mov rcx, [_gcAllocBytes]\n")
+	print("cmp rcx, [_gcNext]\n")
+	print("jae _alloc9\n")
+	print("_alloc8:\n")
+	print("mov r9, [_heapPtr]\n")
+	print("lea rcx, [r9+rbx]\n")
+	print("cmp rcx, [_heapEnd]\n")
+	print("ja _alloc10\n")
+	print("mov [_heapPtr], rcx\n")
+	print("mov [r9], rbx\n")
+	print("mov r10, r9\n")
+	print("sub r10, [_heapBase]\n")
+	print("shr r10, 3\n")
+	print("mov r11, [_heapBits]\n")
+	print("bts [r11], r10\n")
+	// Zero block's data and return pointer to it
+	print("_alloc7:\n")
+	print("add [_gcAllocBytes], rbx\n")
+	print("lea rdi, [r9+8]\n")
+	print("lea rcx, [rbx-8]\n")
+	print("shr rcx, 3\n")
+	print("xor rax, rax\n")
+	print("rep stosq\n")
+	print("lea rax, [r9+8]\n")
+	print("pop r15\n")
+	print("pop r14\n")
+	print("pop r13\n")
+	print("pop r12\n")
+	print("pop r11\n")
+	print("pop r10\n")
+	print("pop r9\n")
+	print("pop r8\n")
+	print("pop rdi\n")
+	print("pop rsi\n")
+	print("pop rdx\n")
+	print("pop rcx\n")
+	print("pop rbx\n")
 	print("pop rbp\n")
 	print("ret 8\n")
+	// Grow heap to at least rcx (by heapGrowth bytes or more), but not
+	// beyond the limit of heapLimit bytes
+	print("_alloc10:\n")
+	print("mov rdi, [_heapEnd]\n")
+	print("add rdi, " + itoa(heapGrowth) + "\n")
+	print("cmp rdi, rcx\n")
+	print("jae _alloc11\n")
+	print("mov rdi, rcx\n")
+	print("_alloc11:\n")
+	print("add rdi, 4095\n") // round up to page size
+	print("and rdi, -4096\n")
+	print("mov rdx, " + itoa(heapLimit) + "\n")
+	print("add rdx, [_heapBase]\n")
+	print("cmp rdi, rdx\n")
+	print("jbe _alloc12\n")
+	print("mov rdi, rdx\n")
+	print("cmp rdi, rcx\n")
+	print("jb _alloc9\n")
+	print("_alloc12:\n")
+	print("mov rax, 12\n") // system call for "brk"
+	print("syscall\n")
+	print("cmp rax, rdi\n")
+	print("jb _alloc9\n")
+	print("mov [_heapEnd], rax\n")
+	print("jmp _alloc8\n")
+	// Collect garbage and try again
+	print("_alloc9:\n")
+	print("test r12, r12\n")
+	print("jnz _outOfMem\n")
+	print("inc r12\n")
+	print("call _gc\n")
+	print("jmp _alloc2\n")
 	print("_outOfMem:\n")
-	print("push qword 14\n") // len("out of memory\n")
-	print("push _strOutOfMem\n")
+	genLogStat("_strOutOfMem", 38, "qword [rbp+16]")
+	print("mov rax, [_gcAllocBytes]\n")
+	print("sub rax, [_gcFreedBytes]\n")
+	genLogStat("_strMemInUse", 8, "rax")
+	print("mov rax, [_heapEnd]\n")
+	print("sub rax, [_heapBase]\n")
+	genLogStat("_strMemHeap", 15, "rax")
+	print("mov rax, " + itoa(heapLimit) + "\n")
+	genLogStat("_strMemLimit", 18, "rax")
+	print("push qword 2\n") // len(")\n")
+	print("push _strMemEnd\n")
 	print("call log\n")
-	print("push qword 1\n")
+	print("push qword 2\n")
 	print("call exit\n")
 	print("\n")
+}
 
-	// Append single integer to []int, allocating and copying as necessary.
-	print("_appendInt:\n")
-	print("push rbp\n") // rbp ret value addr len cap
+// Generate the garbage collector, a conservative mark-sweep collector:
+// any word on the stack, in a global or in a marked block that points into
+// a block marks that block. Marked blocks are pushed on the mark stack
+// (at _markStack) until their data is scanned, so marking doesn't recurse
+// (each block is pushed at most once, so the mark stack has room for the
+// most blocks there can be).
+func genGC() {
+	// Collect garbage (like runtime.GC()). Preserves rbx and r12.
+	print("_gc:\n")
+	print("push rbp\n")
 	print("mov rbp, rsp\n")
-	// Ensure capacity is large enough
-	print("mov rax, [rbp+32]\n") // len
-	print("mov rbx, [rbp+40]\n") // cap
-	print("cmp rax, rbx\n")      // if len >= cap, resize
-	print("jl _appendInt1\n")
-	print("add rbx, rbx\n")    // double in size
-	print("jnz _appendInt2\n") // if it's zero, allocate minimum size
-	print("inc rbx\n")
-	print("_appendInt2:\n")
-	print("mov [rbp+40], rbx\n") // update cap
-	// Allocate newCap*8 bytes
-	print("lea rbx, [rbx*8]\n")
-	print("push rbx\n")
-	print("call _alloc\n")
-	// Move from old array to new
-	print("mov rsi, [rbp+24]\n")
-	print("mov rdi, rax\n")
-	print("mov [rbp+24], rax\n") // update addr
-	print("mov rcx, [rbp+32]\n")
-	print("rep movsq\n")
-	// Set addr[len] = value
-	print("_appendInt1:\n")
-	print("mov rax, [rbp+24]\n") // addr
-	print("mov rbx, [rbp+32]\n") // len
-	print("mov rdx, [rbp+16]\n") // value
-	print("mov [rax+rbx*8], rdx\n")
-	// Return addr len+1 cap (in rax rbx rcx)
-	print("inc rbx\n")
-	print("mov rcx, [rbp+40]\n")
+	print("inc qword [_gcCount]\n")
+	print("mov rax, [_markStack]\n")
+	print("mov [_markTop], rax\n")
+	print("mov r13, rsp\n") // mark from stack
+	print("_gc1:\n")
+	print("cmp r13, [_initialStack]\n")
+	print("jae _gc2\n")
+	print("mov rax, [r13]\n")
+	print("call _gcMark\n")
+	print("add r13, 8\n")
+	print("jmp _gc1\n")
+	print("_gc2:\n")
+	print("mov r13, _globals\n") // mark from globals
+	print("_gc3:\n")
+	print("cmp r13, _globalsEnd\n")
+	print("jae _gc4\n")
+	print("mov rax, [r13]\n")
+	print("call _gcMark\n")
+	print("add r13, 8\n")
+	print("jmp _gc3\n")
+	// Scan the data of the marked blocks on the mark stack, which may
+	// push more
+	print("_gc4:\n")
+	print("mov rax, [_markTop]\n")
+	print("cmp rax, [_markStack]\n")
+	print("jbe _gc6\n")
+	print("sub rax, 8\n")
+	print("mov [_markTop], rax\n")
+	print("mov r14, [rax]\n") // block header
+	print("mov r15, [r14]\n")
+	print("and r15, -4\n")
+	print("add r15, r14\n") // end of block
+	print("add r14, 8\n")
+	print("_gc5:\n")
+	print("cmp r14, r15\n")
+	print("jae _gc4\n")
+	print("mov rax, [r14]\n")
+	print("call _gcMark\n")
+	print("add r14, 8\n")
+	print("jmp _gc5\n")
+	// Sweep: unmark marked blocks, and rebuild the free list from the
+	// unmarked ones (joining adjacent free blocks)
+	print("_gc6:\n")
+	print("mov r8, [_heapBase]\n") // current block
+	print("xor r9, r9\n")          // previous block if it's free, else 0
+	print("mov r10, _freeList\n")  // address of link to fill in
+	print("mov qword [r10], 0\n")
+	print("mov rsi, [_heapBits]\n")
+	print("_gc7:\n")
+	print("cmp r8, [_heapPtr]\n")
+	print("jae _gc11\n")
+	print("mov rax, [r8]\n")
+	print("mov rcx, rax\n")
+	print("and rcx, -4\n") // size
+	print("test rax, 1\n")
+	print("jz _gc8\n")
+	print("mov [r8], rcx\n") // marked: unmark
+	print("xor r9, r9\n")
+	print("jmp _gc10\n")
+	print("_gc8:\n")
+	print("test rax, 2\n")
+	print("jnz _gc9\n")
+	print("add [_gcFreedBytes], rcx\n") // newly freed
+	print("_gc9:\n")
+	print("test r9, r9\n")
+	print("jz _gc12\n")
+	print("add [r9], rcx\n") // join with previous free block
+	print("mov rdx, r8\n")
+	print("sub rdx, [_heapBase]\n")
+	print("shr rdx, 3\n")
+	print("btr [rsi], rdx\n")
+	print("jmp _gc10\n")
+	print("_gc12:\n")
+	print("lea rdx, [rcx+2]\n") // add to end of free list
+	print("mov [r8], rdx\n")
+	print("mov qword [r8+8], 0\n")
+	print("mov [r10], r8\n")
+	print("mov r11, r10\n")
+	print("lea r10, [r8+8]\n")
+	print("mov r9, r8\n")
+	print("_gc10:\n")
+	print("add r8, rcx\n")
+	print("jmp _gc7\n")
+	// If the last block is free, give it back to the end of the heap
+	print("_gc11:\n")
+	print("test r9, r9\n")
+	print("jz _gc13\n")
+	print("mov qword [r11], 0\n")
+	print("mov [_heapPtr], r9\n")
+	print("sub r9, [_heapBase]\n")
+	print("shr r9, 3\n")
+	print("btr [rsi], r9\n")
+	// Next collection is due when as many bytes as are now in use (but at
+	// least gcMinimum) have been allocated
+	print("_gc13:\n")
+	print("mov rax, [_gcAllocBytes]\n")
+	print("sub rax, [_gcFreedBytes]\n")
+	print("cmp rax, " + itoa(gcMinimum) + "\n")
+	print("jae _gc14\n")
+	print("mov rax, " + itoa(gcMinimum) + "\n")
+	print("_gc14:\n")
+	print("add rax, [_gcAllocBytes]\n")
+	print("mov [_gcNext], rax\n")
 	print("pop rbp\n")
-	print("ret 32\n")
+	print("ret\n")
 	print("\n")
 
-	// Append single string to []string, allocating and copying as necessary.
-	print("_appendString:\n")
-	print("push rbp\n") // rbp ret 16strAddr 24strLen 32addr 40len 48cap
-	print("mov rbp, rsp\n")
-	// Ensure capacity is large enough
-	print("mov rax, [rbp+40]\n") // len
-	print("mov rbx, [rbp+48]\n") // cap
-	print("cmp rax, rbx\n")      // if len >= cap, resize
-	print("jl _appendInt3\n")
-	print("add rbx, rbx\n")    // double in size
-	print("jnz _appendInt4\n") // if it's zero, allocate minimum size
-	print("inc rbx\n")
-	print("_appendInt4:\n")
-	print("mov [rbp+48], rbx\n") // update cap
-	// Allocate newCap*16 bytes
-	print("add rbx, rbx\n")
-	print("lea rbx, [rbx*8]\n")
-	print("push rbx\n")
-	print("call _alloc\n")
-	// Move from old array to new
-	print("mov rsi, [rbp+32]\n")
-	print("mov rdi, rax\n")
+	// Mark the block that the word in rax points into (if any) and push it
+	// on the mark stack. The block's header is the nearest one at or
+	// before rax-8: its bit is found a word of _heapBits at a time, by
+	// masking off the bits after rax-8 and using bsr to find the highest
+	// one left.
+	print("_gcMark:\n")
+	print("cmp rax, [_heapPtr]\n")
+	print("jae _gcMark3\n")
+	print("mov rcx, rax\n")
+	print("sub rcx, [_heapBase]\n")
+	print("jb _gcMark3\n")
+	print("sub rcx, 8\n")
+	print("jb _gcMark3\n")
+	print("shr rcx, 3\n")
+	print("mov rsi, [_heapBits]\n")
+	print("mov rdx, rcx\n")
+	print("shr rdx, 6\n") // index of word in _heapBits
+	print("and ecx, 63\n")
+	print("mov r8, 2\n")
+	print("shl r8, cl\n")
+	print("dec r8\n") // bits 0 to cl
+	print("and r8, [rsi+rdx*8]\n")
+	print("jnz _gcMark2\n")
+	print("_gcMark1:\n") // there's always a header below
+	print("dec rdx\n")
+	print("mov r8, [rsi+rdx*8]\n")
+	print("test r8, r8\n")
+	print("jz _gcMark1\n")
+	print("_gcMark2:\n")
+	print("bsr r8, r8\n")
+	print("shl rdx, 6\n")
+	print("add rdx, r8\n")
+	print("shl rdx, 3\n")
+	print("add rdx, [_heapBase]\n")
+	print("mov rax, [rdx]\n")
+	print("test rax, 3\n") // already marked or free
+	print("jnz _gcMark3\n")
+	print("or rax, 1\n")
+	print("mov [rdx], rax\n")
+	print("mov rax, [_markTop]\n")
+	print("mov [rax], rdx\n")
+	print("add qword [_markTop], 8\n")
+	print("_gcMark3:\n")
+	print("ret\n")
+	print("\n")
+
+	// Print garbage collector statistics to stderr.
+	print("_gcStats:\n")
+	genLogStat("_strGCCount", 4, "qword [_gcCount]")
+	genLogStat("_strGCAlloc", 14, "qword [_gcAllocBytes]")
+	genLogStat("_strGCFreed", 18, "qword [_gcFreedBytes]")
+	print("mov rax, [_gcAllocBytes]\n")
+	print("sub rax, [_gcFreedBytes]\n")
+	genLogStat("_strGCInUse", 14, "rax")
+	print("mov rax, [_heapEnd]\n")
+	print("sub rax, [_heapBase]\n")
+	genLogStat("_strGCHeap", 15, "rax")
+	print("push qword 11\n") // len(" byte heap\n")
+	print("push _strGCEnd\n")
+	print("call log\n")
+	print("ret\n")
+	print("\n")
+}
+
+// Generate the allocation profiler (-P flag), which counts allocations
+// and bytes requested for each pair of allocating runtime routine and
+// calling function, and reports them at exit in decreasing order of bytes.
+// Each entry in _profTable is: site address, caller name addr, caller name
+// len, count, bytes.
+func genAllocProfile() {
+	// Called from _alloc (using its frame) to record an allocation. The
+	// caller is the function containing the first return address on the
+	// stack that's in user code.
+	print("_allocProfile:\n")
+	print("cmp qword [_profiling], 0\n")
+	print("je _allocProfile6\n")
+	print("mov rax, [rbp+16]\n") // size
+	print("add [_profBytes], rax\n")
+	print("inc qword [_profCount]\n")
+	print("lea rsi, [rbp+8]\n")
+	print("_allocProfile1:\n")
+	print("mov rax, 1\n") // not found, so name is "?"
+	print("cmp rsi, [_initialStack]\n")
+	print("jae _allocProfile2\n")
+	print("mov rax, [rsi]\n")
+	print("add rsi, 8\n")
+	print("cmp rax, _userCode\n")
+	print("jb _allocProfile1\n")
+	print("cmp rax, _codeEnd\n")
+	print("jae _allocProfile1\n")
+	print("_allocProfile2:\n")
+	print("dec rax\n")
+	print("call _funcName\n")
+	print("mov rdx, [rbp+8]\n") // site
+	print("mov rsi, _profTable\n")
+	print("mov rcx, [_profSites]\n")
+	print("_allocProfile3:\n")
+	print("test rcx, rcx\n")
+	print("jz _allocProfile4\n")
+	print("cmp rdx, [rsi]\n")
+	print("jne _allocProfile5\n")
+	print("cmp rax, [rsi+8]\n")
+	print("je _allocProfile7\n")
+	print("_allocProfile5:\n")
+	print("add rsi, 40\n")
+	print("dec rcx\n")
+	print("jmp _allocProfile3\n")
+	print("_allocProfile4:\n")
+	print("cmp qword [_profSites], " + itoa(maxProfSites) + "\n")
+	print("jae _allocProfile6\n") // table is full
+	print("inc qword [_profSites]\n")
+	print("mov [rsi], rdx\n")
+	print("mov [rsi+8], rax\n")
+	print("mov [rsi+16], rbx\n")
+	print("_allocProfile7:\n")
+	print("inc qword [rsi+24]\n")
+	print("mov rax, [rbp+16]\n")
+	print("add [rsi+32], rax\n")
+	print("_allocProfile6:\n")
+	print("ret\n")
+	print("\n")
+
+	// Print the allocation profile to stderr. Each printed entry's count is
+	// set to 0 so the next biggest can be found.
+	print("_allocReport:\n")
+	genLogStat("_strProfCount", 15, "qword [_profCount]")
+	genLogStat("_strProfBytes", 14, "qword [_profBytes]")
+	print("push qword 7\n") // len(" bytes\n")
+	print("push _strProfEnd\n")
+	print("call log\n")
+	print("_allocReport1:\n")
+	print("xor r12, r12\n") // entry with most bytes
+	print("mov rsi, _profTable\n")
+	print("mov rcx, [_profSites]\n")
+	print("_allocReport2:\n")
+	print("test rcx, rcx\n")
+	print("jz _allocReport4\n")
+	print("cmp qword [rsi+24], 0\n")
+	print("je _allocReport3\n")
+	print("test r12, r12\n")
+	print("jz _allocReport5\n")
+	print("mov rax, [rsi+32]\n")
+	print("cmp rax, [r12+32]\n")
+	print("jle _allocReport3\n")
+	print("_allocReport5:\n")
+	print("mov r12, rsi\n")
+	print("_allocReport3:\n")
+	print("add rsi, 40\n")
+	print("dec rcx\n")
+	print("jmp _allocReport2\n")
+	print("_allocReport4:\n")
+	print("test r12, r12\n")
+	print("jz _allocReport9\n")
+	genLogStat("_strTab", 1, "qword [r12+32]")
+	genLogStat("_strProfIn", 10, "qword [r12+24]")
+	print("push qword 14\n") // len(" allocations: ")
+	print("push _strProfBy\n")
+	print("call log\n")
+	print("mov rax, [r12]\n") // find name of site
+	print("mov rsi, _allocSites\n")
+	print("_allocReport6:\n")
+	print("cmp rsi, _allocSitesEnd\n")
+	print("jae _allocReport8\n")
+	print("cmp rax, [rsi]\n")
+	print("je _allocReport7\n")
+	print("add rsi, 24\n")
+	print("jmp _allocReport6\n")
+	print("_allocReport7:\n")
+	print("push qword [rsi+16]\n")
+	print("push qword [rsi+8]\n")
+	print("call log\n")
+	print("_allocReport8:\n")
+	print("push qword 6\n") // len(" from ")
+	print("push _strProfFrom\n")
+	print("call log\n")
+	print("push qword [r12+16]\n")
+	print("push qword [r12+8]\n")
+	print("call log\n")
+	print("push qword 1\n")
+	print("push _strNewline\n")
+	print("call log\n")
+	print("mov qword [r12+24], 0\n")
+	print("jmp _allocReport1\n")
+	print("_allocReport9:\n")
+	print("ret\n")
+	print("\n")
+}
+
+func genProgramStart() {
+	print("global _start\n")
+	print("section .text\n")
+	print("\n")
+
+	// Initialize and call main.
+	print("_start:\n")
+	print("mov [_initialStack], rsp\n")
+	print("xor rbp, rbp\n") // marks end of frame chain for backtraces
+
+	// Stack limit is initial stack pointer minus the stack size limit
+	// (maxStack if that's lower), leaving stackReserve bytes spare (or half
+	// the limit if that's less, so a small "ulimit -s" still works).
+	print("mov rax, 97\n") // system call for "getrlimit"
+	print("mov rdi, 3\n")  // RLIMIT_STACK
+	print("sub rsp, 16\n")
+	print("mov rsi, rsp\n")
+	print("syscall\n")
+	print("pop rax\n") // soft limit
+	print("pop rbx\n")
+	print("mov rbx, " + itoa(maxStack) + "\n")
+	print("cmp rax, rbx\n")
+	print("jbe _start1\n")
+	print("mov rax, rbx\n") // unlimited or too big
+	print("_start1:\n")
+	print("mov rcx, rax\n")
+	print("shr rcx, 1\n")
+	print("mov rbx, " + itoa(stackReserve) + "\n")
+	print("cmp rcx, rbx\n")
+	print("jbe _start2\n")
+	print("mov rcx, rbx\n")
+	print("_start2:\n")
+	print("sub rax, rcx\n")
+	print("mov rbx, rsp\n")
+	print("sub rbx, rax\n")
+	print("mov [_stackLimit], rbx\n")
+
+	// Heap starts at the initial program break, and the bitmap of its
+	// block headers and the collector's mark stack are mapped with enough
+	// space for the largest heap
+	print("mov rax, 12\n") // system call for "brk"
+	print("xor rdi, rdi\n")
+	print("syscall\n")
+	print("add rax, 7\n")
+	print("and rax, -8\n")
+	print("mov [_heapBase], rax\n")
+	print("mov [_heapPtr], rax\n")
+	print("mov [_heapEnd], rax\n")
+	print("mov rax, 9\n") // system call for "mmap"
+	print("xor rdi, rdi\n")
+	print("mov rsi, " + itoa(heapLimit/64+heapLimit/2) + "\n")
+	print("mov rdx, 3\n")     // PROT_READ|PROT_WRITE
+	print("mov r10, 16418\n") // MAP_PRIVATE|MAP_ANONYMOUS|MAP_NORESERVE
+	print("mov r8, -1\n")
+	print("xor r9, r9\n")
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("jns _start3\n")
+	print("push rsi\n") // report size like _alloc
+	print("sub rsp, 16\n")
+	print("mov rbp, rsp\n")
+	print("jmp _outOfMem\n")
+	print("_start3:\n")
+	print("mov [_heapBits], rax\n")
+	print("add rax, " + itoa(heapLimit/64) + "\n")
+	print("mov [_markStack], rax\n")
+	print("mov qword [_gcNext], " + itoa(gcMinimum) + "\n")
+	if allocProfile == 1 {
+		print("mov qword [_profiling], 1\n")
+	}
+
+	// Handle SIGSEGV by printing a backtrace
+	print("sub rsp, 32\n") // struct sigaction: handler flags restorer mask
+	print("mov qword [rsp], _segfault\n")
+	print("mov qword [rsp+8], 67108868\n") // SA_SIGINFO|SA_RESTORER
+	print("mov qword [rsp+16], _sigreturn\n")
+	print("mov qword [rsp+24], 0\n")
+	print("mov rax, 13\n") // system call for "rt_sigaction"
+	print("mov rdi, 11\n") // SIGSEGV
+	print("mov rsi, rsp\n")
+	print("xor rdx, rdx\n")
+	print("mov r10, 8\n") // size of signal mask
+	print("syscall\n")
+	print("add rsp, 32\n")
+	print("call _init\n")
+	print("call main\n")
+	print("push qword 0\n")
+	print("call exit\n")
+	print("\n")
+
+	// Write the rdx bytes at rsi to file descriptor rdi, retrying short
+	// writes. Preserves all registers except rax, rcx and r11.
+	print("_writeAll:\n")
+	print("push rsi\n")
+	print("push rdx\n")
+	print("_writeAll1:\n")
+	print("test rdx, rdx\n")
+	print("jle _writeAll2\n")
+	print("mov rax, 1\n") // system call for "write"
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("jle _writeAll2\n") // give up on error
+	print("add rsi, rax\n")
+	print("sub rdx, rax\n")
+	print("jmp _writeAll1\n")
+	print("_writeAll2:\n")
+	print("pop rdx\n")
+	print("pop rsi\n")
+	print("ret\n")
+	print("\n")
+
+	// Write out any buffered stdout. Preserves all registers.
+	print("_flush:\n")
+	print("push rax\n")
+	print("push rcx\n")
+	print("push rdx\n")
+	print("push rsi\n")
+	print("push rdi\n")
+	print("push r11\n")
+	print("mov rdi, 1\n") // file handle 1 is stdout
+	print("mov rsi, _outBuf\n")
+	print("mov rdx, [_outLen]\n")
+	print("call _writeAll\n")
+	print("mov qword [_outLen], 0\n")
+	print("pop r11\n")
+	print("pop rdi\n")
+	print("pop rsi\n")
+	print("pop rdx\n")
+	print("pop rcx\n")
+	print("pop rax\n")
+	print("ret\n")
+	print("\n")
+
+	// Write a string to stdout, via a buffer that's flushed when it's full,
+	// before writing to stderr or a file, and at exit.
+	print("print:\n")
+	print("push rbp\n") // rbp ret addr len
+	print("mov rbp, rsp\n")
+	print("mov rsi, [rbp+16]\n") // address
+	print("mov rdx, [rbp+24]\n") // length
+	print("mov rax, [_outLen]\n")
+	print("add rax, rdx\n")
+	print("cmp rax, " + itoa(ioBufSize) + "\n")
+	print("jbe _print1\n")
+	print("call _flush\n")
+	print("cmp rdx, " + itoa(ioBufSize) + "\n")
+	print("jbe _print1\n")
+	print("mov rdi, 1\n") // too big to buffer, write it directly
+	print("call _writeAll\n")
+	print("jmp _print2\n")
+	print("_print1:\n")
+	print("mov rdi, _outBuf\n")
+	print("add rdi, [_outLen]\n")
+	print("add [_outLen], rdx\n")
+	print("mov rcx, rdx\n")
+	print("rep movsb\n")
+	print("_print2:\n")
+	print("pop rbp\n")
+	print("ret 16\n")
+	print("\n")
+
+	// Write a string to stderr (unbuffered, after flushing stdout).
+	print("log:\n")
+	print("call _flush\n")
+	print("mov rdi, 2\n")        // file handle 2 is stderr
+	print("mov rsi, [rsp+8]\n")  // address
+	print("mov rdx, [rsp+16]\n") // length
+	print("call _writeAll\n")
+	print("ret 16\n")
+	print("\n")
+
+	// Read a single byte from stdin, or return -1 on EOF. Reads are
+	// buffered, so mixing getc with other reads of stdin isn't supported.
+	print("getc:\n")
+	print("mov rax, [_inPos]\n")
+	print("cmp rax, [_inLen]\n")
+	print("jb _getc1\n")
+	print("xor rax, rax\n") // system call for "read"
+	print("xor rdi, rdi\n") // file handle 0 is stdin
+	print("mov rsi, _inBuf\n")
+	print("mov rdx, " + itoa(ioBufSize) + "\n")
+	print("syscall\n")
+	print("test rax, rax\n")
+	print("jle _getc2\n")
+	print("mov [_inLen], rax\n")
+	print("xor rax, rax\n")
+	print("_getc1:\n")
+	print("movzx rbx, byte [_inBuf+rax]\n")
+	print("inc rax\n")
+	print("mov [_inPos], rax\n")
+	print("mov rax, rbx\n")
+	print("ret\n")
+	print("_getc2:\n")
+	print("mov rax, -1\n")
+	print("ret\n")
+	print("\n")
+
+	// Like os.Exit().
+	print("exit:\n")
+	if allocProfile == 1 {
+		print("mov qword [_profiling], 0\n") // don't count allocations from here on
+	}
+	print("call _flush\n")
+	if gcStats == 1 {
+		print("call _gcStats\n")
+	}
+	if allocProfile == 1 {
+		print("call _allocReport\n")
+	}
+	print("mov rdi, [rsp+8]\n") // code
+	print("mov rax, 60\n")      // system call for "exit"
+	print("syscall\n")
+	print("\n")
+
+	// Return the IEEE 754 bits of a float64 (for strconv.FormatFloat).
+	print("_floatBits:\n")
+	print("mov rax, [rsp+8]\n") // value
+	print("ret 8\n")
+	print("\n")
+
+	// Convert uint64 to float64 (halving values too big for cvtsi2sd,
+	// keeping the low bit for correct rounding).
+	print("_uintToFloat:\n")
+	print("mov rax, [rsp+8]\n")
+	print("test rax, rax\n")
+	print("js _uintToFloat1\n")
+	print("cvtsi2sd xmm0, rax\n")
+	print("movq rax, xmm0\n")
+	print("ret 8\n")
+	print("_uintToFloat1:\n")
+	print("mov rbx, rax\n")
+	print("shr rax, 1\n")
+	print("and rbx, 1\n")
+	print("or rax, rbx\n")
+	print("cvtsi2sd xmm0, rax\n")
+	print("addsd xmm0, xmm0\n")
+	print("movq rax, xmm0\n")
+	print("ret 8\n")
+	print("\n")
+
+	// Convert float64 to uint64 (truncating towards zero).
+	print("_floatToUint:\n")
+	print("movsd xmm0, [rsp+8]\n")
+	print("mov rax, 4890909195324358656\n") // 2**63 as a float64
+	print("movq xmm1, rax\n")
+	print("ucomisd xmm0, xmm1\n")
+	print("jae _floatToUint1\n")
+	print("cvttsd2si rax, xmm0\n")
+	print("ret 8\n")
+	print("_floatToUint1:\n")
+	print("subsd xmm0, xmm1\n")
+	print("cvttsd2si rax, xmm0\n")
+	print("btc rax, 63\n")
+	print("ret 8\n")
+	print("\n")
+
+	// Return concatenation of two strings.
+	print("_strAdd:\n")
+	print("push rbp\n") // rbp ret addr1 len1 addr0 len0
+	print("mov rbp, rsp\n")
+	// Allocate len0+len1 bytes
+	print("mov rax, [rbp+24]\n") // len1
+	print("add rax, [rbp+40]\n") // len1 + len0
+	print("push rax\n")
+	genAllocCall("_strAdd")
+	// Move len0 bytes from addr0 to addrNew
+	print("mov rsi, [rbp+32]\n")
+	print("mov rdi, rax\n")
+	print("mov rcx, [rbp+40]\n")
+	print("rep movsb\n")
+	// Move len1 bytes from addr1 to addrNew+len0
+	print("mov rsi, [rbp+16]\n")
+	print("mov rdi, rax\n")
+	print("add rdi, [rbp+40]\n")
+	print("mov rcx, [rbp+24]\n")
+	print("rep movsb\n")
+	// Return addrNew len0+len1 (addrNew already in rax)
+	print("mov rbx, [rbp+24]\n")
+	print("add rbx, [rbp+40]\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Return true if strings are equal.
+	print("_strEq:\n")
+	print("push rbp\n") // rbp ret addr1 len1 addr0 len0
+	print("mov rbp, rsp\n")
+	print("mov rcx, [rbp+40]\n")
+	print("cmp rcx, [rbp+24]\n")
+	print("jne _strEqNotEqual\n")
+	print("mov rsi, [rbp+16]\n")
+	print("mov rdi, [rbp+32]\n")
+	print("rep cmpsb\n")
+	print("jne _strEqNotEqual\n")
+	print("mov rax, 1\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	// Return addrNew len0+len1 (addrNew already in rax)
+	print("_strEqNotEqual:\n")
+	print("xor rax, rax\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Compare strings byte-wise, returning -1 if first is less than
+	// second, 0 if they're equal, or 1 if first is greater.
+	print("_strCmp:\n")
+	print("push rbp\n") // rbp ret addr1 len1 addr0 len0
+	print("mov rbp, rsp\n")
+	print("mov rcx, [rbp+40]\n") // compare min(len0, len1) bytes
+	print("cmp rcx, [rbp+24]\n")
+	print("jbe _strCmp1\n")
+	print("mov rcx, [rbp+24]\n")
+	print("_strCmp1:\n")
+	print("mov rsi, [rbp+32]\n")
+	print("mov rdi, [rbp+16]\n")
+	print("xor rax, rax\n") // sets flags to "equal" in case rcx is 0
+	print("repe cmpsb\n")
+	print("jb _strCmpLess\n")
+	print("ja _strCmpGreater\n")
+	print("mov rbx, [rbp+40]\n") // common prefix is equal, compare lengths
+	print("cmp rbx, [rbp+24]\n")
+	print("jb _strCmpLess\n")
+	print("ja _strCmpGreater\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("_strCmpLess:\n")
+	print("mov rax, -1\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("_strCmpGreater:\n")
+	print("mov rax, 1\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Return new 1-byte string from integer character.
+	print("char:\n")
+	print("push rbp\n") // rbp ret ch
+	print("mov rbp, rsp\n")
+	// Allocate 1 byte
+	print("push 1\n")
+	genAllocCall("char")
+	// Move byte to destination
+	print("mov rbx, [rbp+16]\n")
+	print("mov [rax], bl\n")
+	// Return addrNew 1 (addrNew already in rax)
+	print("mov rbx, 1\n")
+	print("pop rbp\n")
+	print("ret 8\n")
+	print("\n")
+
+	genAlloc()
+	genGC()
+	if allocProfile == 1 {
+		genAllocProfile()
+	}
+
+	// Append single integer to []int, allocating and copying as necessary.
+	print("_appendInt:\n")
+	print("push rbp\n") // rbp ret value addr len cap
+	print("mov rbp, rsp\n")
+	// Ensure capacity is large enough
+	print("mov rax, [rbp+32]\n") // len
+	print("mov rbx, [rbp+40]\n") // cap
+	print("cmp rax, rbx\n")      // if len >= cap, resize
+	print("jl _appendInt1\n")
+	print("add rbx, rbx\n")    // double in size
+	print("jnz _appendInt2\n") // if it's zero, allocate minimum size
+	print("inc rbx\n")
+	print("_appendInt2:\n")
+	print("mov [rbp+40], rbx\n") // update cap
+	// Allocate newCap*8 bytes
+	print("lea rbx, [rbx*8]\n")
+	print("push rbx\n")
+	genAllocCall("_appendInt")
+	// Move from old array to new
+	print("mov rsi, [rbp+24]\n")
+	print("mov rdi, rax\n")
+	print("mov [rbp+24], rax\n") // update addr
+	print("mov rcx, [rbp+32]\n")
+	print("rep movsq\n")
+	// Set addr[len] = value
+	print("_appendInt1:\n")
+	print("mov rax, [rbp+24]\n") // addr
+	print("mov rbx, [rbp+32]\n") // len
+	print("mov rdx, [rbp+16]\n") // value
+	print("mov [rax+rbx*8], rdx\n")
+	// Return addr len+1 cap (in rax rbx rcx)
+	print("inc rbx\n")
+	print("mov rcx, [rbp+40]\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Append single string to []string, allocating and copying as necessary.
+	print("_appendString:\n")
+	print("push rbp\n") // rbp ret 16strAddr 24strLen 32addr 40len 48cap
+	print("mov rbp, rsp\n")
+	// Ensure capacity is large enough
+	print("mov rax, [rbp+40]\n") // len
+	print("mov rbx, [rbp+48]\n") // cap
+	print("cmp rax, rbx\n")      // if len >= cap, resize
+	print("jl _appendInt3\n")
+	print("add rbx, rbx\n")    // double in size
+	print("jnz _appendInt4\n") // if it's zero, allocate minimum size
+	print("inc rbx\n")
+	print("_appendInt4:\n")
+	print("mov [rbp+48], rbx\n") // update cap
+	// Allocate newCap*16 bytes
+	print("add rbx, rbx\n")
+	print("lea rbx, [rbx*8]\n")
+	print("push rbx\n")
+	genAllocCall("_appendString")
+	// Move from old array to new
+	print("mov rsi, [rbp+32]\n")
+	print("mov rdi, rax\n")
 	print("mov [rbp+32], rax\n") // update addr
 	print("mov rcx, [rbp+40]\n")
 	print("add rcx, rcx\n")
@@ -590,593 +2469,3173 @@ func genProgramStart() {
 	print("mov rcx, [rbp+48]\n")
 	print("pop rbp\n")
 	print("ret 40\n")
+	print("\n")
+
+	// Append single byte to []byte, allocating and copying as necessary.
+	print("_appendByte:\n")
+	print("push rbp\n") // rbp ret value addr len cap
+	print("mov rbp, rsp\n")
+	// Ensure capacity is large enough
+	print("mov rax, [rbp+32]\n") // len
+	print("mov rbx, [rbp+40]\n") // cap
+	print("cmp rax, rbx\n")      // if len >= cap, resize
+	print("jl _appendByte1\n")
+	print("add rbx, rbx\n")     // double in size
+	print("jnz _appendByte2\n") // if it's zero, allocate minimum size
+	print("mov rbx, 8\n")
+	print("_appendByte2:\n")
+	print("mov [rbp+40], rbx\n") // update cap
+	// Allocate newCap bytes
+	print("push rbx\n")
+	genAllocCall("_appendByte")
+	// Move from old array to new
+	print("mov rsi, [rbp+24]\n")
+	print("mov rdi, rax\n")
+	print("mov [rbp+24], rax\n") // update addr
+	print("mov rcx, [rbp+32]\n")
+	print("rep movsb\n")
+	// Set addr[len] = value
+	print("_appendByte1:\n")
+	print("mov rax, [rbp+24]\n") // addr
+	print("mov rbx, [rbp+32]\n") // len
+	print("mov rdx, [rbp+16]\n") // value
+	print("mov [rax+rbx], dl\n")
+	// Return addr len+1 cap (in rax rbx rcx)
+	print("inc rbx\n")
+	print("mov rcx, [rbp+40]\n")
+	print("pop rbp\n")
+	print("ret 32\n")
+	print("\n")
+
+	// Append all elements of a slice to another slice ("append(s, t...)"),
+	// allocating and copying as necessary. Element size is in r8.
+	print("_appendBytes:\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("mov r8, 1\n") // element size of []byte
+	print("jmp _appendSlice\n")
+	print("_appendInts:\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("mov r8, 8\n") // element size of []int
+	print("jmp _appendSlice\n")
+	print("_appendStrings:\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("mov r8, 16\n")    // element size of []string
+	print("_appendSlice:\n") // rbp ret 16addr2 24len2 32cap2 40addr 48len 56cap
+	// Ensure capacity is large enough
+	print("mov rax, [rbp+48]\n") // len
+	print("add rax, [rbp+24]\n") // len + len2
+	print("mov rbx, [rbp+56]\n") // cap
+	print("cmp rax, rbx\n")      // if len+len2 > cap, resize
+	print("jle _appendSlice2\n")
+	print("add rbx, rbx\n") // double in size
+	print("cmp rbx, rax\n") // but make it at least len+len2
+	print("jge _appendSlice1\n")
+	print("mov rbx, rax\n")
+	print("_appendSlice1:\n")
+	print("mov [rbp+56], rbx\n") // update cap
+	// Allocate newCap*size bytes
+	print("imul rbx, r8\n")
+	print("push rbx\n")
+	genAllocCall("_appendSlice")
+	// Move from old array to new
+	print("mov rsi, [rbp+40]\n")
+	print("mov rdi, rax\n")
+	print("mov [rbp+40], rax\n") // update addr
+	print("mov rcx, [rbp+48]\n")
+	print("imul rcx, r8\n")
+	print("rep movsb\n")
+	// Move len2 elements from addr2 to addr[len]
+	print("_appendSlice2:\n")
+	print("mov rdi, [rbp+48]\n")
+	print("imul rdi, r8\n")
+	print("add rdi, [rbp+40]\n")
+	print("mov rsi, [rbp+16]\n")
+	print("mov rcx, [rbp+24]\n")
+	print("imul rcx, r8\n")
+	print("cmp rdi, rsi\n") // copy backwards if destination is after source
+	print("jbe _appendSlice3\n")
+	print("lea rsi, [rsi+rcx-1]\n")
+	print("lea rdi, [rdi+rcx-1]\n")
+	print("std\n")
+	print("rep movsb\n")
+	print("cld\n")
+	print("jmp _appendSlice4\n")
+	print("_appendSlice3:\n")
+	print("rep movsb\n")
+	// Return addr len+len2 cap (in rax rbx rcx)
+	print("_appendSlice4:\n")
+	print("mov rax, [rbp+40]\n")
+	print("mov rbx, [rbp+48]\n")
+	print("add rbx, [rbp+24]\n")
+	print("mov rcx, [rbp+56]\n")
+	print("pop rbp\n")
+	print("ret 48\n")
+	print("\n")
+
+	// Convert string to new []byte ("[]byte(s)").
+	print("_stringToBytes:\n")
+	print("push rbp\n") // rbp ret addr len
+	print("mov rbp, rsp\n")
+	print("push qword [rbp+24]\n")
+	genAllocCall("_stringToBytes")
+	print("mov rsi, [rbp+16]\n")
+	print("mov rdi, rax\n")
+	print("mov rcx, [rbp+24]\n")
+	print("rep movsb\n")
+	// Return addrNew len len (addrNew already in rax)
+	print("mov rbx, [rbp+24]\n")
+	print("mov rcx, rbx\n")
+	print("pop rbp\n")
+	print("ret 16\n")
+	print("\n")
+
+	// Convert []byte to new string ("string(b)").
+	print("_bytesToString:\n")
+	print("push rbp\n") // rbp ret addr len cap
+	print("mov rbp, rsp\n")
+	print("push qword [rbp+24]\n")
+	genAllocCall("_bytesToString")
+	print("mov rsi, [rbp+16]\n")
+	print("mov rdi, rax\n")
+	print("mov rcx, [rbp+24]\n")
+	print("rep movsb\n")
+	// Return addrNew len (addrNew already in rax)
+	print("mov rbx, [rbp+24]\n")
+	print("pop rbp\n")
+	print("ret 24\n")
+	print("\n")
+
+	// Decode the UTF-8 encoded rune at index of string for "range" loop
+	// (loop state is left on the stack). Return rune and its length in bytes,
+	// or "\uFFFD" and 1 if the encoding is invalid.
+	print("_decodeRune:\n") // ret index addr len
+	print("mov rsi, [rsp+16]\n")
+	print("add rsi, [rsp+8]\n") // rsi = addr+index
+	print("mov rcx, [rsp+24]\n")
+	print("sub rcx, [rsp+8]\n") // rcx = bytes remaining
+	print("movzx eax, byte [rsi]\n")
+	print("mov rbx, 1\n")
+	print("cmp rax, 128\n")
+	print("jb _decodeRune4\n") // ASCII
+	print("mov rbx, 2\n")      // length
+	print("mov rdx, 128\n")    // minimum code point (to catch overlong forms)
+	print("and rax, 31\n")
+	print("cmp byte [rsi], 194\n")
+	print("jb _decodeRune5\n")
+	print("cmp byte [rsi], 224\n")
+	print("jb _decodeRune1\n")
+	print("mov rbx, 3\n")
+	print("mov rdx, 2048\n")
+	print("and rax, 15\n")
+	print("cmp byte [rsi], 240\n")
+	print("jb _decodeRune1\n")
+	print("mov rbx, 4\n")
+	print("mov rdx, 65536\n")
+	print("and rax, 7\n")
+	print("cmp byte [rsi], 245\n")
+	print("jae _decodeRune5\n")
+	print("_decodeRune1:\n")
+	print("cmp rcx, rbx\n")
+	print("jb _decodeRune5\n")
+	print("mov r8, 1\n")
+	print("_decodeRune2:\n") // add continuation bytes (10xxxxxx)
+	print("movzx edi, byte [rsi+r8]\n")
+	print("mov r9, rdi\n")
+	print("and r9, 192\n")
+	print("cmp r9, 128\n")
+	print("jne _decodeRune5\n")
+	print("shl rax, 6\n")
+	print("and rdi, 63\n")
+	print("or rax, rdi\n")
+	print("inc r8\n")
+	print("cmp r8, rbx\n")
+	print("jb _decodeRune2\n")
+	print("cmp rax, rdx\n")
+	print("jb _decodeRune5\n")
+	print("cmp rax, 1114111\n")
+	print("ja _decodeRune5\n")
+	print("mov r9, rax\n")
+	print("and r9, -2048\n")
+	print("cmp r9, 55296\n") // surrogate halves 0xD800-0xDFFF
+	print("je _decodeRune5\n")
+	print("_decodeRune4:\n")
+	print("ret\n")
+	print("_decodeRune5:\n")
+	print("mov rax, 65533\n")
+	print("mov rbx, 1\n")
+	print("ret\n")
+	print("\n")
+
+	// Return new string with UTF-8 encoding of code point ("string(r)").
+	// Invalid code points are encoded as "\uFFFD".
+	print("_runeToString:\n")
+	print("push rbp\n") // rbp ret r
+	print("mov rbp, rsp\n")
+	print("sub rsp, 8\n")        // space for encoded bytes
+	print("mov rax, [rbp+16]\n") // r
+	print("cmp rax, 1114111\n")  // unsigned compare also catches r < 0
+	print("ja _runeToString1\n")
+	print("mov rbx, rax\n")
+	print("and rbx, -2048\n")
+	print("cmp rbx, 55296\n") // surrogate halves 0xD800-0xDFFF
+	print("jne _runeToString2\n")
+	print("_runeToString1:\n")
+	print("mov rax, 65533\n") // 0xFFFD
+	print("_runeToString2:\n")
+	print("lea rdi, [rbp-8]\n")
+	print("cmp rax, 128\n")
+	print("jae _runeToString3\n")
+	print("mov [rdi], al\n") // 1 byte: 0xxxxxxx
+	print("mov r9, 1\n")
+	print("jmp _runeToString6\n")
+	print("_runeToString3:\n")
+	print("mov r9, 2\n") // number of bytes
+	print("mov rdx, 192\n")
+	print("cmp rax, 2048\n")
+	print("jb _runeToString4\n")
+	print("mov r9, 3\n")
+	print("mov rdx, 224\n")
+	print("cmp rax, 65536\n")
+	print("jb _runeToString4\n")
+	print("mov r9, 4\n")
+	print("mov rdx, 240\n")
+	print("_runeToString4:\n")
+	// Write continuation bytes (10xxxxxx) backwards, then the first byte
+	print("mov rcx, r9\n")
+	print("_runeToString5:\n")
+	print("dec rcx\n")
+	print("jz _runeToString7\n")
+	print("mov bl, al\n")
+	print("and bl, 63\n")
+	print("or bl, 128\n")
+	print("mov [rdi+rcx], bl\n")
+	print("shr rax, 6\n")
+	print("jmp _runeToString5\n")
+	print("_runeToString7:\n")
+	print("or al, dl\n")
+	print("mov [rdi], al\n")
+	print("_runeToString6:\n")
+	// Allocate and copy bytes
+	print("push r9\n")
+	genAllocCall("_runeToString")
+	print("lea rsi, [rbp-8]\n")
+	print("mov rdi, rax\n")
+	print("mov rcx, r9\n")
+	print("rep movsb\n")
+	// Return addrNew length (addrNew already in rax)
+	print("mov rbx, r9\n")
+	print("mov rsp, rbp\n")
+	print("pop rbp\n")
+	print("ret 8\n")
+	print("\n")
+
+	// Return a pointer to a heap copy of the string on the stack. Used to
+	// store a string in an any, and by errors.New (an error is a pointer to
+	// its message, or 0 if nil).
+	print("_newError:\n")
+	print("_boxString:\n")
+	print("push qword 16\n")
+	genAllocCall("_boxString")
+	print("mov rbx, [rsp+8]\n") // ret addr len
+	print("mov [rax], rbx\n")
+	print("mov rbx, [rsp+16]\n")
+	print("mov [rax+8], rbx\n")
+	print("ret 16\n")
+	print("\n")
+
+	// Return an error's message.
+	print("error.Error:\n")
+	print("mov rax, [rsp+8]\n") // error
+	print("mov rbx, [rax+8]\n")
+	print("mov rax, [rax]\n")
+	print("ret 8\n")
+	print("\n")
+
+	// Panic for a failed type assertion from an any holding type tag rbx
+	// to type tag rcx.
+	print("_panicAssert:\n")
+	print("mov r15, [rsp]\n") // for backtrace (see _panic)
+	print("dec r15\n")
+	print("mov r12, rbp\n")
+	print("push rcx\n")
+	print("push rbx\n")
+	print("push qword 45\n") // len("panic: interface conversion: interface {} is ")
+	print("push _strAssert\n")
+	print("call log\n")
+	print("pop rax\n")
+	print("call _logTypeName\n")
+	print("push qword 6\n") // len(", not ")
+	print("push _strAssertNot\n")
+	print("call log\n")
+	print("pop rax\n")
+	print("call _logTypeName\n")
+	print("push qword 0\n") // no source position
+	print("push qword 0\n")
+	print("jmp _panicEnd\n")
+	print("\n")
+
+	// Log the name of the type with tag rax.
+	print("_logTypeName:\n")
+	print("shl rax, 4\n")
+	print("push qword [_typeNames+rax+8]\n")
+	print("push qword [_typeNames+rax]\n")
+	print("call log\n")
+	print("ret\n")
+	print("\n")
+
+	// Return string length
+	print("len:\n")
+	print("push rbp\n") // rbp ret addr len
+	print("mov rbp, rsp\n")
+	print("mov rax, [rbp+24]\n")
+	print("pop rbp\n")
+	print("ret 16\n")
+	print("\n")
+
+	// Return slice length
+	print("_lenSlice:\n")
+	print("push rbp\n") // rbp ret addr len cap
+	print("mov rbp, rsp\n")
+	print("mov rax, [rbp+24]\n")
+	print("pop rbp\n")
+	print("ret 24\n")
+	print("\n")
+
+	// Return slice capacity
+	print("_capSlice:\n")
+	print("push rbp\n") // rbp ret addr len cap
+	print("mov rbp, rsp\n")
+	print("mov rax, [rbp+32]\n")
+	print("pop rbp\n")
+	print("ret 24\n")
+	print("\n")
+
+	// Return new zeroed slice with given length and capacity ("make").
+	print("_makeSlice:\n")
+	print("push rbp\n") // rbp ret 16size 24cap 32len
+	print("mov rbp, rsp\n")
+	print("mov rax, [rbp+32]\n")
+	print("cmp rax, 0\n")
+	print("jl _makeSliceLen\n")
+	print("cmp rax, [rbp+24]\n")
+	print("jg _makeSliceCap\n")
+	// Allocate cap*size bytes (heap memory is already zeroed)
+	print("mov rbx, [rbp+24]\n")
+	print("imul rbx, [rbp+16]\n")
+	print("jo _makeSliceBig\n")
+	print("push rbx\n")
+	genAllocCall("_makeSlice")
+	// Return addr len cap (addr already in rax)
+	print("mov rbx, [rbp+32]\n")
+	print("mov rcx, [rbp+24]\n")
+	print("pop rbp\n")
+	print("ret 24\n")
+	print("_makeSliceBig:\n") // len is out of range if len*size overflows too
+	print("mov rbx, [rbp+32]\n")
+	print("imul rbx, [rbp+16]\n")
+	print("jno _makeSliceCap\n")
+	print("_makeSliceLen:\n")
+	print("pop rbp\n") // so the backtrace starts at the caller
+	print("mov rax, _strMakeLen\n")
+	print("mov rbx, 27\n") // len("makeslice: len out of range")
+	print("xor r11, r11\n")
+	print("jmp _panic\n")
+	print("_makeSliceCap:\n")
+	print("pop rbp\n")
+	print("mov rax, _strMakeCap\n")
+	print("mov rbx, 27\n") // len("makeslice: cap out of range")
+	print("xor r11, r11\n")
+	print("jmp _panic\n")
+	print("\n")
+
+	// Copy min(len, len2) elements from one slice to another (which may
+	// overlap), and return the number of elements copied ("copy").
+	print("_copy:\n")
+	print("push rbp\n") // rbp ret 16size 24addr2 32len2 40cap2 48addr 56len 64cap
+	print("mov rbp, rsp\n")
+	print("mov rcx, [rbp+56]\n") // len
+	print("cmp rcx, [rbp+32]\n") // len2
+	print("jle _copy1\n")
+	print("mov rcx, [rbp+32]\n")
+	print("_copy1:\n")
+	print("mov rax, rcx\n") // number of elements to return
+	print("imul rcx, [rbp+16]\n")
+	print("mov rsi, [rbp+24]\n")
+	print("mov rdi, [rbp+48]\n")
+	print("cmp rdi, rsi\n") // copy backwards if destination is after source
+	print("jbe _copy2\n")
+	print("lea rsi, [rsi+rcx-1]\n")
+	print("lea rdi, [rdi+rcx-1]\n")
+	print("std\n")
+	print("rep movsb\n")
+	print("cld\n")
+	print("pop rbp\n")
+	print("ret 56\n")
+	print("_copy2:\n")
+	print("rep movsb\n")
+	print("pop rbp\n")
+	print("ret 56\n")
+	print("\n")
+
+	// Print "panic: runtime error: " and the message in rax (address) and
+	// rbx (length) to stderr, followed by a backtrace, and exit with status
+	// 2. The top of the stack is the return address into the panicking
+	// function, and the source position is in r10 (address) and r11
+	// (length, 0 if unknown).
+	print("_panic:\n")
+	print("mov r15, [rsp]\n")
+	print("dec r15\n") // return address may be the start of the next function
+	print("mov r12, rbp\n")
+	print("push r11\n") // syscalls clobber r11
+	print("push r10\n")
+	print("push rbx\n")
+	print("push rax\n")
+	print("push qword 22\n") // len("panic: runtime error: ")
+	print("push _strPanic\n")
+	print("call log\n")
+	print("call log\n") // message pushed above
+	// Print the end of a panic message and the backtrace, with the
+	// position pushed above.
+	print("_panicEnd:\n")
+	print("push qword 1\n")
+	print("push _strNewline\n")
+	print("call log\n")
+	print("pop r10\n")
+	print("pop r11\n")
+	print("call _backtrace\n")
+	print("push qword 2\n")
+	print("call exit\n")
+	print("\n")
+
+	genPanicBounds("_panicIndex", "_strIndex", 20, "_strWithLen", 14)
+	genPanicBounds("_panicSlice", "_strSlice", 28, "_strWithCap", 16)
+
+	// Panic routines for arithmetic errors (source position in r10 and r11).
+	print("_panicDivide:\n")
+	print("mov rax, _strDivide\n")
+	print("mov rbx, 22\n") // len("integer divide by zero")
+	print("jmp _panic\n")
+	print("_panicOverflow:\n")
+	print("mov rax, _strOverflow\n")
+	print("mov rbx, 16\n") // len("integer overflow")
+	print("jmp _panic\n")
+	print("\n")
+
+	// Return the name of the function containing code address rax in rax
+	// (address) and rbx (length), or "?" if it's not in a function.
+	print("_funcName:\n")
+	print("mov rdx, rax\n")
+	print("mov rax, _strUnknownFunc\n")
+	print("mov rbx, 1\n")
+	print("mov rsi, _funcTable\n") // entries are: label, name addr, name len
+	print("_funcName1:\n")
+	print("cmp rsi, _funcTableEnd\n")
+	print("jae _funcName2\n")
+	print("cmp rdx, [rsi]\n")
+	print("jb _funcName2\n")
+	print("mov rax, [rsi+8]\n")
+	print("mov rbx, [rsi+16]\n")
+	print("add rsi, 24\n")
+	print("jmp _funcName1\n")
+	print("_funcName2:\n")
+	print("ret\n")
+	print("\n")
+
+	// Print a backtrace of function names to stderr, one per line, by
+	// walking the rbp chain. The innermost frame is the function containing
+	// address r15, whose frame pointer is r12, and its source position is
+	// in r10 (address) and r11 (length, 0 if unknown). If there are more
+	// than 20 frames, only the first and last 10 are shown.
+	print("_backtrace:\n")
+	print("push r11\n")
+	print("push r10\n")
+	print("mov rax, r12\n") // count frames into r13
+	print("mov r13, 1\n")
+	print("_backtrace1:\n")
+	print("test rax, rax\n")
+	print("jz _backtrace2\n")
+	print("cmp qword [rax], 0\n")
+	print("je _backtrace2\n")
+	print("mov rax, [rax]\n")
+	print("inc r13\n")
+	print("jmp _backtrace1\n")
+	print("_backtrace2:\n")
+	print("sub r13, 10\n")  // index of first of last 10 frames
+	print("xor r14, r14\n") // frame index
+	print("_backtrace3:\n")
+	print("cmp r14, 10\n")
+	print("jl _backtrace4\n")
+	print("cmp r14, r13\n")
+	print("jge _backtrace4\n")
+	print("cmp r14, 10\n")
+	print("jne _backtrace6\n")
+	print("push qword 32\n") // len("\t...additional frames elided...\n")
+	print("push _strElided\n")
+	print("call log\n")
+	print("jmp _backtrace6\n")
+	print("_backtrace4:\n")
+	print("push qword 1\n")
+	print("push _strTab\n")
+	print("call log\n")
+	print("mov rax, r15\n")
+	print("call _funcName\n")
+	print("push rbx\n")
+	print("push rax\n")
+	print("call log\n")
+	print("test r14, r14\n") // position is only known for innermost frame
+	print("jnz _backtrace5\n")
+	print("cmp qword [rsp+8], 0\n")
+	print("je _backtrace5\n")
+	print("push qword 4\n") // len(" at ")
+	print("push _strAt\n")
+	print("call log\n")
+	print("push qword [rsp+8]\n")
+	print("push qword [rsp+8]\n")
+	print("call log\n")
+	print("_backtrace5:\n")
+	print("push qword 1\n")
+	print("push _strNewline\n")
+	print("call log\n")
+	print("_backtrace6:\n")
+	print("test r12, r12\n")
+	print("jz _backtrace7\n")
+	print("cmp qword [r12], 0\n") // main's frame is the last
+	print("je _backtrace7\n")
+	print("mov r15, [r12+8]\n")
+	print("dec r15\n")
+	print("mov r12, [r12]\n")
+	print("inc r14\n")
+	print("jmp _backtrace3\n")
+	print("_backtrace7:\n")
+	print("add rsp, 16\n")
+	print("ret\n")
+	print("\n")
+
+	// Called from a function prologue when the stack limit is reached.
+	// Print the function name and a backtrace, and exit with status 2.
+	print("_stackOverflow:\n")
+	print("mov r15, [rsp]\n") // return address in overflowing function
+	print("mov r12, rbp\n")
+	print("mov rax, r15\n")
+	print("call _funcName\n")
+	print("push rbx\n")
+	print("push rax\n")
+	print("push qword 31\n") // len("fatal error: stack overflow in ")
+	print("push _strStackOverflow\n")
+	print("call log\n")
+	print("call log\n") // function name pushed above
+	print("push qword 1\n")
+	print("push _strNewline\n")
+	print("call log\n")
+	print("xor r11, r11\n")
+	print("call _backtrace\n")
+	print("push qword 2\n")
+	print("call exit\n")
+	print("\n")
+
+	// SIGSEGV handler (with SA_SIGINFO, so rdx is the ucontext). Print a
+	// backtrace from the faulting instruction and exit with status 2.
+	print("_segfault:\n")
+	print("mov r15, [rdx+168]\n") // uc_mcontext.gregs[REG_RIP]
+	print("mov r12, [rdx+120]\n") // uc_mcontext.gregs[REG_RBP]
+	print("push qword 32\n")      // len("fatal error: segmentation fault\n")
+	print("push _strSegfault\n")
+	print("call log\n")
+	print("xor r11, r11\n")
+	print("call _backtrace\n")
+	print("push qword 2\n")
+	print("call exit\n")
+	print("_sigreturn:\n") // never used, but x86-64 requires SA_RESTORER
+	print("mov rax, 15\n") // system call for "rt_sigreturn"
+	print("syscall\n")
+	print("\n")
+
+	// Print the signed integer in rax to stderr.
+	print("_logInt:\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("sub rsp, 24\n") // room for the digits and sign
+	print("mov rsi, rbp\n")
+	print("mov r8, rax\n")
+	print("cmp rax, 0\n")
+	print("jge _logInt1\n")
+	print("neg rax\n") // the most negative int works as unsigned
+	print("_logInt1:\n")
+	print("xor rdx, rdx\n")
+	print("mov rbx, 10\n")
+	print("div rbx\n")
+	print("add dl, '0'\n")
+	print("dec rsi\n")
+	print("mov [rsi], dl\n")
+	print("cmp rax, 0\n")
+	print("jne _logInt1\n")
+	print("cmp r8, 0\n")
+	print("jge _logInt2\n")
+	print("dec rsi\n")
+	print("mov byte [rsi], '-'\n")
+	print("_logInt2:\n")
+	print("mov rax, rbp\n")
+	print("sub rax, rsi\n")
+	print("push rax\n")
+	print("push rsi\n")
+	print("call log\n")
+	print("mov rsp, rbp\n")
+	print("pop rbp\n")
+	print("ret\n")
+	print("\n")
+
+	// Return the command-line arguments or the environment as a []string.
+	print("args:\n")
+	print("mov rdx, [_initialStack]\n") // argc argv0 argv1 ... 0 env0 env1 ... 0
+	print("add rdx, 8\n")
+	print("jmp _cStrings\n")
+	print("_environ:\n")
+	print("mov rdx, [_initialStack]\n")
+	print("mov rax, [rdx]\n")
+	print("lea rdx, [rdx+rax*8+16]\n")
+
+	// Return the NUL-terminated strings in the null-terminated array of
+	// pointers at rdx as a []string.
+	print("_cStrings:\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("xor r8, r8\n") // count strings
+	print("_cStrings1:\n")
+	print("cmp qword [rdx+r8*8], 0\n")
+	print("je _cStrings2\n")
+	print("inc r8\n")
+	print("jmp _cStrings1\n")
+	print("_cStrings2:\n")
+	print("mov rbx, r8\n")
+	print("shl rbx, 4\n") // 16 bytes per string
+	print("push rbx\n")
+	genAllocCall("_cStrings")
+	print("mov rdi, rax\n")
+	print("xor r9, r9\n") // string index
+	print("_cStrings3:\n")
+	print("cmp r9, r8\n")
+	print("jge _cStrings6\n")
+	print("mov rsi, [rdx+r9*8]\n")
+	print("xor rcx, rcx\n")
+	print("_cStrings4:\n")
+	print("cmp byte [rsi+rcx], 0\n")
+	print("je _cStrings5\n")
+	print("inc rcx\n")
+	print("jmp _cStrings4\n")
+	print("_cStrings5:\n")
+	print("mov [rdi], rsi\n")
+	print("mov [rdi+8], rcx\n")
+	print("add rdi, 16\n")
+	print("inc r9\n")
+	print("jmp _cStrings3\n")
+	// Return addr count count (addr already in rax)
+	print("_cStrings6:\n")
+	print("mov rbx, r8\n")
+	print("mov rcx, r8\n")
+	print("pop rbp\n")
+	print("ret\n")
+	print("\n")
+
+	genFileIO()
+
+	print("_userCode:\n") // user code follows (for the allocation profile)
+}
+
+func genConst(name string, value int) {
+	print(name + " equ " + itoa(value) + "\n")
+}
+
+func genIntLit(n int) {
+	if n < -2147483648 || n > 2147483647 {
+		// Too big for push's 32-bit immediate
+		print("mov rax, " + itoa(n) + "\n")
+		print("push rax\n")
+		return
+	}
+	print("push qword " + itoa(n) + "\n")
+}
+
+// Return the index in floats of the float64 nearest to the given decimal,
+// adding it if needed (floats are pushed from memory as they're 64 bits).
+func floatIndex(neg bool, digits string, exp int) int {
+	s := itoa(floatBits(neg, digits, exp))
+	index := find(floats, s)
+	if index < 0 {
+		index = len(floats)
+		floats = append(floats, s)
+	}
+	return index
+}
+
+// Push the float64 nearest to the given decimal.
+func genFloatLit(neg bool, digits string, exp int) {
+	print("push qword [flt" + itoa(floatIndex(neg, digits, exp)) + "]\n")
+}
+
+// Push the untyped constant in untypedNeg etc, as a value of its default
+// type (int or float64).
+func genUntyped() {
+	if untypedKind == typeFloat {
+		genFloatLit(untypedNeg, untypedDigits, untypedExp)
+		return
+	}
+	n, _ := decToInt(untypedNeg, untypedDigits, untypedExp)
+	genIntLit(n)
+}
+
+// Return the index in strs of string constant s, adding it if needed.
+func strIndex(s string) int {
+	index := find(strs, s)
+	if index < 0 {
+		// Haven't seen this string constant before, add a new one
+		index = len(strs)
+		strs = append(strs, s)
+	}
+	return index
+}
+
+func genStrLit(s string) {
+	// Push string struct: length and then address (by label)
+	print("push qword " + itoa(len(s)) + "\n")
+	print("push qword str" + itoa(strIndex(s)) + "\n")
+}
+
+func typeName(typ int) string {
+	return types[typ]
+}
+
+func typeSize(typ int) int {
+	return typeSizes[typ]
+}
+
+// Add a type and return it.
+func newType(name string, size int, kind int, elem int) int {
+	types = append(types, name)
+	typeSizes = append(typeSizes, size)
+	typeKinds = append(typeKinds, kind)
+	typeElems = append(typeElems, elem)
+	return len(types) - 1
+}
+
+// Return the underlying type of typ (typ itself unless it's a named type).
+func underType(typ int) int {
+	if typeKinds[typ] == kindNamed {
+		return typeElems[typ]
+	}
+	return typ
+}
+
+func isSlice(typ int) bool {
+	return typeKinds[underType(typ)] == kindSlice
+}
+
+func isPointer(typ int) bool {
+	return typeKinds[underType(typ)] == kindPointer
+}
+
+// Return the element type of slice or pointer type typ.
+func elemType(typ int) int {
+	return typeElems[underType(typ)]
+}
+
+func isInteger(typ int) bool {
+	under := underType(typ)
+	return under == typeInt || under >= typeInt8 && under <= typeUintptr
+}
+
+func isUnsigned(typ int) bool {
+	under := underType(typ)
+	return under >= typeUint8 && under <= typeUintptr
+}
+
+func isNumeric(typ int) bool {
+	return isInteger(typ) || underType(typ) == typeFloat
+}
+
+// Report whether type typ has property prop (propNumeric and so on). A type
+// parameter has it if every type in its constraint's type set does.
+func hasProp(typ int, prop int) bool {
+	if typeKinds[typ] == kindParam {
+		c := typeElems[typ]
+		if c <= 0 {
+			return c < 0 && prop == propComparable
+		}
+		i := c - 1
+		for unionTypes[i] != 0 {
+			term := unionTypes[i]
+			if term < 0 {
+				term = -term
+			}
+			if !hasProp(term, prop) {
+				return false
+			}
+			i = i + 1
+		}
+		return true
+	}
+	under := underType(typ)
+	if prop == propNumeric {
+		return isNumeric(typ)
+	} else if prop == propInteger {
+		return isInteger(typ)
+	} else if prop == propOrdered {
+		return isNumeric(typ) || under == typeString
+	}
+	return isNumeric(typ) || under == typeString || under == typeBool ||
+		under == typeError || isPointer(typ)
+}
+
+// Return the largest value of integer type typ (or the magnitude of the
+// smallest if neg is true) as a nat.
+func intLimit(typ int, neg bool) string {
+	under := underType(typ)
+	if isUnsigned(typ) && neg {
+		return "0"
+	} else if under == typeInt8 && neg {
+		return "128"
+	} else if under == typeInt8 {
+		return "127"
+	} else if under == typeInt16 && neg {
+		return "32768"
+	} else if under == typeInt16 {
+		return "32767"
+	} else if under == typeInt32 && neg {
+		return "2147483648"
+	} else if under == typeInt32 {
+		return "2147483647"
+	} else if under == typeUint8 {
+		return "255"
+	} else if under == typeUint16 {
+		return "65535"
+	} else if under == typeUint32 {
+		return "4294967295"
+	} else if isUnsigned(typ) {
+		return "18446744073709551615"
+	} else if neg {
+		return "9223372036854775808"
+	}
+	return "9223372036854775807"
+}
+
+// Return the 64 bits of integer constant digits (negated if neg is true),
+// checking that it's an integer that fits in type typ.
+func constBits(neg bool, digits string, exp int, typ int) int {
+	if exp < 0 {
+		error("constant " + decString(neg, digits, exp) + " truncated to integer")
+	}
+	digits = natShift(digits, exp)
+	if natCmp(digits, intLimit(typ, neg)) > 0 {
+		error("constant " + decString(neg, digits, 0) + " overflows " + typeName(typ))
+	}
+	if natCmp(digits, "9223372036854775807") > 0 && !neg {
+		// Too big for an int: wrap around to negative
+		neg = true
+		digits = natSub("18446744073709551616", digits)
+	}
+	n, _ := decToInt(neg, digits, 0)
+	return n
+}
+
+// Return true if nil can be assigned to or compared with type typ.
+func canBeNil(typ int) bool {
+	under := underType(typ)
+	return isPointer(typ) || under == typeError || under == typeAny ||
+		typeKinds[under] == kindFunc || typeKinds[under] == kindSlice
+}
+
+// Return the type named name, or 0 if there's no such type.
+func findType(name string) int {
+	i := 0
+	for i < scopeNumParams {
+		if typeParams[scopeParams+i] == name && scopeArgs < 0 {
+			return typeParamTypes[scopeParams+i]
+		} else if typeParams[scopeParams+i] == name {
+			return instanceTypeArgs[scopeArgs+i]
+		}
+		i = i + 1
+	}
+	if name == "byte" {
+		name = "uint8"
+	} else if name == "rune" {
+		name = "int32"
+	}
+	i = typeVoid + 1
+	for i < len(types) {
+		if types[i] == name && typeKinds[i] != kindParam {
+			return i
+		}
+		i = i + 1
+	}
+	return 0
+}
+
+// Return the number of values in tuple type typ (1 if it's not a tuple).
+func tupleLen(typ int) int {
+	if typeKinds[typ] != kindTuple {
+		return 1
+	}
+	return tupleTypes[typeElems[typ]]
+}
+
+// Return the type of value i in tuple type typ.
+func tupleElem(typ int, i int) int {
+	if typeKinds[typ] != kindTuple {
+		return typ
+	}
+	return tupleTypes[typeElems[typ]+1+i]
+}
+
+// Report whether a[i:i+n] and b[j:j+n] are equal.
+func equalInts(a []int, i int, b []int, j int, n int) bool {
+	k := 0
+	for k < n {
+		if a[i+k] != b[j+k] {
+			return false
+		}
+		k = k + 1
+	}
+	return true
+}
+
+// Return the tuple type for the list of types at tupleTypes[start:] (N
+// followed by N types), reusing an existing tuple type if there is one.
+func tupleType(start int) int {
+	n := tupleTypes[start]
+	name := "("
+	size := 0
+	i := 0
+	for i < n {
+		if i > 0 {
+			name = name + ", "
+		}
+		name = name + typeName(tupleTypes[start+1+i])
+		size = size + typeSize(tupleTypes[start+1+i])
+		i = i + 1
+	}
+	name = name + ")"
+	typ := 0
+	i = 0
+	for i < len(types) {
+		if typeKinds[i] == kindTuple && types[i] == name {
+			if equalInts(tupleTypes, typeElems[i], tupleTypes, start, n+1) {
+				typ = i
+			}
+		}
+		i = i + 1
+	}
+	if typ != 0 {
+		tupleTypes = tupleTypes[:start]
+		return typ
+	}
+	return newType(name, size, kindTuple, start)
+}
+
+// Return the type with the given name, kind and element type, adding it if
+// needed. The name alone doesn't identify it, as type parameters of
+// different generics may have the same name.
+func derivedType(name string, size int, kind int, elem int) int {
+	i := 0
+	for i < len(types) {
+		if typeKinds[i] == kind && typeElems[i] == elem && types[i] == name {
+			return i
+		}
+		i = i + 1
+	}
+	return newType(name, size, kind, elem)
+}
+
+// Return the type of pointers to typ, adding it if needed.
+func pointerType(typ int) int {
+	return derivedType("*"+typeName(typ), 8, kindPointer, typ)
+}
+
+// Return the type of slices with elements of type typ, adding it if needed.
+func sliceType(typ int) int {
+	if typeSize(typ) != 8 && typeSize(typ) != 16 {
+		error("slices of " + typeName(typ) + " are not supported")
+	}
+	return derivedType("[]"+typeName(typ), 24, kindSlice, typ)
+}
+
+// Return the func type with the signature at sigStack[start:] (result type,
+// N, then N parameter types), adding it if needed, and pop the signature.
+func funcType(start int) int {
+	n := sigStack[start+1]
+	typ := 0
+	i := 0
+	for i < len(types) {
+		if typeKinds[i] == kindFunc {
+			if equalInts(funcTypeSigs, typeElems[i], sigStack, start, n+2) {
+				typ = i
+			}
+		}
+		i = i + 1
+	}
+	if typ == 0 {
+		name := "func("
+		i = 0
+		for i < n {
+			if i > 0 {
+				name = name + ", "
+			}
+			name = name + typeName(sigStack[start+2+i])
+			i = i + 1
+		}
+		name = name + ")"
+		if sigStack[start] != typeVoid {
+			name = name + " " + typeName(sigStack[start])
+		}
+		typ = newType(name, 8, kindFunc, len(funcTypeSigs))
+		i = 0
+		for i < n+2 {
+			funcTypeSigs = append(funcTypeSigs, sigStack[start+i])
+			i = i + 1
+		}
+	}
+	sigStack = sigStack[:start]
+	return typ
+}
+
+// Return the func type of function index (in funcs).
+func funcTypeOf(index int) int {
+	sigIndex := funcSigIndexes[index]
+	start := len(sigStack)
+	n := funcSigs[sigIndex+1] + 2
+	i := 0
+	for i < n {
+		sigStack = append(sigStack, funcSigs[sigIndex+i])
+		i = i + 1
+	}
+	return funcType(start)
+}
+
+// Return true if typ is a slice of bytes, whose elements are stored in one
+// byte each.
+func isByteSlice(typ int) bool {
+	return isSlice(typ) && underType(elemType(typ)) == typeUint8
+}
+
+// Return offset of local variable from rbp (including arguments).
+func localOffset(index int) int {
+	funcIndex := find(funcs, curFunc)
+	sigIndex := funcSigIndexes[funcIndex]
+	numArgs := funcSigs[sigIndex+1]
+	if index < numArgs {
+		// Function argument local (add to rbp; args are on stack in reverse)
+		offset := 16
+		i := numArgs - 1
+		for i > index {
+			offset = offset + typeSize(localTypes[i])
+			i = i - 1
+		}
+		return offset
+	} else {
+		// Declared local (subtract from rbp)
+		offset := 0
+		i := numArgs
+		for i <= index {
+			offset = offset - typeSize(localTypes[i])
+			i = i + 1
+		}
+		return offset
+	}
+}
+
+func genFetchInstrs(typ int, addr string) {
+	offset := typeSize(typ) - 8
+	for offset > 0 {
+		print("push qword [" + addr + "+" + itoa(offset) + "]\n")
+		offset = offset - 8
+	}
+	print("push qword [" + addr + "]\n")
+}
+
+func genLocalFetch(index int) int {
+	offset := localOffset(index)
+	typ := localTypes[index]
+	genFetchInstrs(typ, "rbp+"+itoa(offset))
+	return typ
+}
+
+func genGlobalFetch(index int) int {
+	name := globals[index]
+	typ := globalTypes[index]
+	genFetchInstrs(typ, name)
+	return typ
+}
+
+func genConstFetch(index int) int {
+	if constUntyped[index] {
+		untypedKind = constTypes[index]
+		untypedNeg, untypedDigits, untypedExp = decParse(constValues[index])
+		genUntyped()
+		return untypedKind
+	} else if underType(constTypes[index]) == typeFloat {
+		neg, digits, exp := decParse(constValues[index])
+		genFloatLit(neg, digits, exp)
+		return constTypes[index]
+	}
+	name := consts[index]
+	if len(constValues[index]) > 9 {
+		// May be too big for push's 32-bit immediate
+		print("mov rax, " + name + "\n")
+		print("push rax\n")
+		return constTypes[index]
+	}
+	print("push qword " + name + "\n")
+	return constTypes[index]
+}
+
+func genIdentifier(name string) int {
+	localIndex := find(locals, name)
+	if localIndex >= 0 {
+		return genLocalFetch(localIndex)
+	}
+	globalIndex := find(globals, name)
+	if globalIndex >= 0 {
+		return genGlobalFetch(globalIndex)
+	}
+	constIndex := find(consts, name)
+	if constIndex >= 0 {
+		return genConstFetch(constIndex)
+	}
+	if name == "nil" {
+		print("push qword 0\n")
+		return typeNil
+	}
+	funcIndex := find(funcs, name)
+	if funcIndex >= 0 {
+		// Function value (its address)
+		if find(generics, name) >= 0 {
+			error("cannot use generic function " + name + " without instantiation")
+		} else if funcVariadics[funcIndex] != 0 {
+			error("cannot use variadic function " + name + " as value")
+		}
+		print("push qword " + name + "\n")
+		return funcTypeOf(funcIndex)
+	}
+	error("identifier " + escape(name, "\"") + " not defined")
+	return 0
+}
+
+// Push the address of variable name.
+func genAddress(name string) {
+	localIndex := find(locals, name)
+	if localIndex >= 0 {
+		print("lea rax, [rbp+" + itoa(localOffset(localIndex)) + "]\n")
+		print("push rax\n")
+		return
+	}
+	if find(globals, name) < 0 {
+		error("cannot take address of " + escape(name, "\""))
+	}
+	print("push qword " + name + "\n")
+}
+
+func genAssignInstrs(typ int, addr string) {
+	print("pop qword [" + addr + "]\n")
+	offset := 8
+	for offset < typeSize(typ) {
+		print("pop qword [" + addr + "+" + itoa(offset) + "]\n")
+		offset = offset + 8
+	}
+}
+
+func genLocalAssign(index int) {
+	offset := localOffset(index)
+	genAssignInstrs(localTypes[index], "rbp+"+itoa(offset))
+}
+
+func genGlobalAssign(index int) {
+	name := globals[index]
+	genAssignInstrs(globalTypes[index], name)
+}
+
+func genAssign(name string) {
+	localIndex := find(locals, name)
+	if localIndex >= 0 {
+		genLocalAssign(localIndex)
+		return
+	}
+	globalIndex := find(globals, name)
+	if globalIndex >= 0 {
+		genGlobalAssign(globalIndex)
+		return
+	}
+	error("identifier " + escape(name, "\"") + " not defined (or not assignable)")
+}
+
+func varType(name string) int {
+	localIndex := find(locals, name)
+	if localIndex >= 0 {
+		return localTypes[localIndex]
+	}
+	globalIndex := find(globals, name)
+	if globalIndex >= 0 {
+		return globalTypes[globalIndex]
+	}
+	error("identifier " + escape(name, "\"") + " not defined")
+	return 0
+}
+
+func genLabel(label string) {
+	print("\n")
+	print(label + ":\n")
+}
+
+func newLabel() string {
+	labelNum = labelNum + 1
+	return "label" + itoa(labelNum)
+}
+
+// Return the source position of line srcLine for runtime error messages:
+// "file.go:line", or "line n" when the program is read from stdin.
+func srcPos(srcLine int) string {
+	if fileNames[curFile] == "" {
+		return "line " + itoa(srcLine)
+	}
+	return fileNames[curFile] + ":" + itoa(srcLine)
+}
+
+// Check that index is less than length (or less than or equal if jumpOK
+// is "jbe"), otherwise call panicFunc with index in r8, length in r9 and
+// the source position of line srcLine in r10 and r11. The unsigned compare
+// catches negative indexes too.
+func genBoundsCheck(index string, length string, jumpOK string, panicFunc string, srcLine int) {
+	if noBounds == 1 {
+		return
+	}
+	okLabel := newLabel()
+	pos := srcPos(srcLine)
+	print("cmp " + index + ", " + length + "\n")
+	print(jumpOK + " " + okLabel + "\n")
+	print("mov r8, " + index + "\n")
+	print("mov r9, " + length + "\n")
+	print("mov r10, str" + itoa(strIndex(pos)) + "\n")
+	print("mov r11, " + itoa(len(pos)) + "\n")
+	print("call " + panicFunc + "\n")
+	genLabel(okLabel)
+}
+
+func genSliceAssign(name string, srcLine int) {
+	size := typeSize(elemType(varType(name)))
+	print("pop rax\n") // value (addr if string type)
+	if size == 16 {
+		print("pop rbx\n") // value (len)
+	}
+	print("pop rcx\n") // index
+	addr := name
+	localIndex := find(locals, name)
+	if localIndex >= 0 {
+		addr = "rbp+" + itoa(localOffset(localIndex))
+	}
+	print("mov rdx, [" + addr + "]\n")
+	genBoundsCheck("rcx", "["+addr+"+8]", "jb", "_panicIndex", srcLine)
+	if isByteSlice(varType(name)) {
+		print("mov [rdx+rcx], al\n")
+		return
+	}
+	if size == 16 {
+		print("add rcx, rcx\n") // index * 2
+		print("mov [rdx+rcx*8+8], rbx\n")
+	}
+	print("mov [rdx+rcx*8], rax\n")
+}
+
+// Return the register that word i of a function's result is returned in.
+func resultReg(i int) string {
+	if i == 0 {
+		return "rax"
+	} else if i == 1 {
+		return "rbx"
+	} else if i == 2 {
+		return "rcx"
+	} else if i == 3 {
+		return "rdx"
+	} else if i == 4 {
+		return "rsi"
+	}
+	return "rdi"
+}
+
+// Call function name, whose result is of type resultType, and push the
+// result (which is returned in registers).
+func genCallType(name string, resultType int) int {
+	print("call " + name + "\n")
+	i := typeSize(resultType) / 8
+	for i > 0 {
+		i = i - 1
+		print("push " + resultReg(i) + "\n")
+	}
+	return resultType
+}
+
+func genCall(name string) int {
+	index := find(funcs, name)
+	return genCallType(name, funcSigs[funcSigIndexes[index]])
+}
+
+func genFuncStart(name string) {
+	print("\n")
+	print(name + ":\n")
+	print("push rbp\n")
+	print("mov rbp, rsp\n")
+	print("sub rsp, " + itoa(localSpace) + "\n") // space for locals
+	okLabel := newLabel()
+	print("cmp rsp, [_stackLimit]\n")
+	print("jae " + okLabel + "\n")
+	print("call _stackOverflow\n")
+	genLabel(okLabel)
+
+	codeLabels = append(codeLabels, name)
+	if curPackage == "main" {
+		name = "main." + name
+	}
+	codeNames = append(codeNames, name)
+}
+
+// Return size (in bytes) of current function's arguments.
+func argsSize() int {
+	i := find(funcs, curFunc)
+	sigIndex := funcSigIndexes[i]
+	numArgs := funcSigs[sigIndex+1]
+	size := 0
+	i = 0
+	for i < numArgs {
+		size = size + typeSize(funcSigs[sigIndex+2+i])
+		i = i + 1
+	}
+	return size
+}
+
+// Return size (in bytes) of current function's locals (excluding arguments).
+func localsSize() int {
+	i := find(funcs, curFunc)
+	sigIndex := funcSigIndexes[i]
+	numArgs := funcSigs[sigIndex+1]
+	size := 0
+	i = numArgs
+	for i < len(locals) {
+		size = size + typeSize(localTypes[i])
+		i = i + 1
+	}
+	return size
+}
+
+func genFuncEnd() {
+	size := localsSize()
+	if size > localSpace {
+		error(curFunc + "'s locals too big (" + itoa(size) + " > " + itoa(localSpace) + ")\n")
+	}
+	print("mov rsp, rbp\n")
+	print("pop rbp\n")
+	size = argsSize()
+	if size > 0 {
+		print("ret " + itoa(size) + "\n")
+	} else {
+		print("ret\n")
+	}
+}
+
+// Return the name of type typ as shown in type assertion panics (type tag 0
+// is a nil any).
+func anyTypeName(typ int) string {
+	if typ == 0 {
+		return "nil"
+	} else if typ == typeError {
+		return "*errors.errorString"
+	}
+	return typeName(typ)
+}
+
+// Push the zero value of type typ. Every zero value is all zero words: 0,
+// false, "", and nil pointers, slices, funcs and anys.
+func genZero(typ int) {
+	i := typeSize(typ) / 8
+	for i > 0 {
+		print("push qword 0\n")
+		i = i - 1
+	}
+}
+
+// Return the data directive for a global holding the zero value of type typ.
+func zeroData(typ int) string {
+	words := "dq 0"
+	i := typeSize(typ) / 8
+	for i > 1 {
+		words = words + ", 0"
+		i = i - 1
+	}
+	return words
+}
+
+// Replace the untyped nil on top of the stack with the nil value of type typ.
+func genNil(typ int) {
+	if typeSize(typ) != 8 {
+		print("pop rax\n")
+		genZero(typ)
+	}
+}
+
+// Generate _init, which is called before main to set os.Args (if package
+// os is used) and then call the package init functions.
+func genInit() {
+	print("\n")
+	print("_init:\n")
+	if find(globals, "os.Args") >= 0 {
+		print("call args\n")
+		print("mov [os.Args], rax\n")
+		print("mov [os.Args+8], rbx\n")
+		print("mov [os.Args+16], rcx\n")
+	}
+	i := 0
+	for i < len(initFuncs) {
+		print("call " + initFuncs[i] + "\n")
+		i = i + 1
+	}
+	print("ret\n")
+}
+
+func genDataSections() {
+	print("\n")
+	print("section .data\n")
+	print("_strOutOfMem: db `fatal error: out of memory allocating `\n")
+	print("_strMemInUse: db ` bytes (`\n")
+	print("_strMemHeap: db ` bytes in use, `\n")
+	print("_strMemLimit: db ` byte heap, limit `\n")
+	print("_strMemEnd: db `)\\n`\n")
+	print("_strAssert: db `panic: interface conversion: interface {} is `\n")
+	print("_strAssertNot: db `, not `\n")
+	print("_strNewline: db `\\n`\n")
+	print("_strPanic: db `panic: runtime error: `\n")
+	print("_strMakeLen: db `makeslice: len out of range`\n")
+	print("_strMakeCap: db `makeslice: cap out of range`\n")
+	print("_strAt: db ` at `\n")
+	print("_strIndex: db `index out of range [`\n")
+	print("_strWithLen: db `] with length `\n")
+	print("_strSlice: db `slice bounds out of range [:`\n")
+	print("_strWithCap: db `] with capacity `\n")
+	print("_strDivide: db `integer divide by zero`\n")
+	print("_strOverflow: db `integer overflow`\n")
+	print("_strStackOverflow: db `fatal error: stack overflow in `\n")
+	print("_strSegfault: db `fatal error: segmentation fault\\n`\n")
+	print("_strElided: db `\\t...additional frames elided...\\n`\n")
+	print("_strTab: db `\\t`\n")
+	print("_strUnknownFunc: db `?`\n")
+	print("_strGCCount: db `gc: `\n")
+	print("_strGCAlloc: db ` collections, `\n")
+	print("_strGCFreed: db ` bytes allocated, `\n")
+	print("_strGCInUse: db ` bytes freed, `\n")
+	print("_strGCHeap: db ` bytes in use, `\n")
+	print("_strGCEnd: db ` byte heap\\n`\n")
+	print("_strProfCount: db `alloc profile: `\n")
+	print("_strProfBytes: db ` allocations, `\n")
+	print("_strProfEnd: db ` bytes\\n`\n")
+	print("_strProfIn: db ` bytes in `\n")
+	print("_strProfBy: db ` allocations: `\n")
+	print("_strProfFrom: db ` from `\n")
+
+	// Function names for backtraces
+	i := 0
+	for i < len(codeNames) {
+		print("_fname" + itoa(i) + ": db " + escape(codeNames[i], "`") + "\n")
+		i = i + 1
+	}
+	i = 0
+	for i < len(allocSites) {
+		print("_sname" + itoa(i) + ": db " + escape(allocSites[i], "`") + "\n")
+		i = i + 1
+	}
+
+	// Floating-point constants
+	i = 0
+	for i < len(floats) {
+		print("flt" + itoa(i) + ": dq " + floats[i] + "\n")
+		i = i + 1
+	}
+
+	// String constants
+	i = 0
+	for i < len(strs) {
+		print("str" + itoa(i) + ": db " + escape(strs[i], "`") + "\n")
+		i = i + 1
+	}
+
+	// Type names for type assertion panics, indexed by type tag
+	i = 0
+	for i < len(types) {
+		print("_typeName" + itoa(i) + ": db " + escape(anyTypeName(i), "`") + "\n")
+		i = i + 1
+	}
+	print("align 8\n")
+	print("_typeNames:\n")
+	i = 0
+	for i < len(types) {
+		print("dq _typeName" + itoa(i) + ", " + itoa(len(anyTypeName(i))) + "\n")
+		i = i + 1
+	}
+	print("_funcTable:\n")
+	i = 0
+	for i < len(codeLabels) {
+		print("dq " + codeLabels[i] + ", _fname" + itoa(i) + ", " +
+			itoa(len(codeNames[i])) + "\n")
+		i = i + 1
+	}
+	print("_funcTableEnd:\n")
+	if allocProfile == 1 {
+		print("_allocSites:\n") // entries are: site, name addr, name len
+		i = 0
+		for i < len(allocSites) {
+			print("dq _allocSite" + itoa(i) + ", _sname" + itoa(i) + ", " +
+				itoa(len(allocSites[i])) + "\n")
+			i = i + 1
+		}
+		print("_allocSitesEnd:\n")
+	}
+
+	// Global variables (strings are address, length; slices are address,
+	// length, capacity), which the garbage collector scans
+	print("_globals:\n")
+	i = 0
+	for i < len(globals) {
+		print(globals[i] + ": " + zeroData(globalTypes[i]) + "\n")
+		i = i + 1
+	}
+	print("_globalsEnd:\n")
+
+	// Heap and garbage collector state (see genAlloc)
+	print("\n")
+	print("section .bss\n")
+	print("_heapBase: resq 1\n")
+	print("_heapPtr: resq 1\n")
+	print("_heapEnd: resq 1\n")
+	print("_heapBits: resq 1\n")
+	print("_initialStack: resq 1\n")
+	print("_stackLimit: resq 1\n")
+	print("_freeList: resq 1\n")
+	print("_gcCount: resq 1\n")
+	print("_gcNext: resq 1\n")
+	print("_gcAllocBytes: resq 1\n")
+	print("_gcFreedBytes: resq 1\n")
+	print("_markStack: resq 1\n")
+	print("_markTop: resq 1\n")
+	if allocProfile == 1 {
+		print("_profiling: resq 1\n")
+		print("_profCount: resq 1\n")
+		print("_profBytes: resq 1\n")
+		print("_profSites: resq 1\n")
+		print("_profTable: resq " + itoa(5*maxProfSites) + "\n")
+	}
+	print("_outLen: resq 1\n")
+	print("_inPos: resq 1\n")
+	print("_inLen: resq 1\n")
+	print("_outBuf: resb " + itoa(ioBufSize) + "\n")
+	print("_inBuf: resb " + itoa(ioBufSize) + "\n")
+}
+
+// Truncate the integer in rax to the size of integer type typ, and sign or
+// zero extend it back to 64 bits (values are always stored extended).
+func genTruncate(typ int) {
+	under := underType(typ)
+	if under == typeInt8 {
+		print("movsx rax, al\n")
+	} else if under == typeInt16 {
+		print("movsx rax, ax\n")
+	} else if under == typeInt32 {
+		print("movsxd rax, eax\n")
+	} else if under == typeUint8 {
+		print("movzx eax, al\n")
+	} else if under == typeUint16 {
+		print("movzx eax, ax\n")
+	} else if under == typeUint32 {
+		print("mov eax, eax\n") // clears top 32 bits
+	}
+}
+
+func genUnary(op int, typ int) {
+	if typeKinds[typ] == kindParam {
+		if op == tNot || !hasProp(typ, propNumeric) {
+			error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+		}
+		return // see genBinaryParam
+	}
+	if op == tNot {
+		if underType(typ) != typeBool {
+			error("operator ! not defined on " + typeName(typ))
+		}
+	} else if !isNumeric(typ) {
+		error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+	}
+	if underType(typ) == typeFloat {
+		if op == tMinus {
+			print("btc qword [rsp], 63\n") // flip sign bit
+		}
+		return
+	}
+	print("pop rax\n")
+	if op == tMinus {
+		print("neg rax\n")
+		genTruncate(typ)
+	} else if op == tNot {
+		print("xor rax, 1\n")
+	}
+	print("push rax\n")
+}
+
+// Generate an integer comparison of rax and rbx using set instruction
+// setInstr.
+func genCompareInt(setInstr string) {
+	print("cmp rax, rbx\n")
+	print("mov rax, 0\n")
+	print(setInstr + " al\n")
+}
+
+// Compare the two strings on top of the stack with _strCmp and push the
+// result of the comparison using set instruction setInstr.
+func genCompareString(setInstr string) int {
+	print("call _strCmp\n")
+	print("xor rbx, rbx\n")
+	genCompareInt(setInstr)
+	print("push rax\n")
+	return typeBool
+}
+
+func genBinaryString(op int, typ int) int {
+	if op == tPlus {
+		print("call _strAdd\n")
+		print("push rbx\n")
+		print("push rax\n")
+		return typ
+	} else if op == tEq {
+		print("call _strEq\n")
+		print("push rax\n")
+		return typeBool
+	} else if op == tNotEq {
+		print("call _strEq\n")
+		print("xor rax, 1\n")
+		print("push rax\n")
+		return typeBool
+	} else if op == tLess {
+		return genCompareString("setl")
+	} else if op == tLessEq {
+		return genCompareString("setle")
+	} else if op == tGreater {
+		return genCompareString("setg")
+	} else if op == tGreaterEq {
+		return genCompareString("setge")
+	} else {
+		error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+		return 0
+	}
+}
+
+func isComparison(op int) bool {
+	return op == tEq || op == tNotEq || op == tLess || op == tLessEq ||
+		op == tGreater || op == tGreaterEq
+}
+
+func genJumpIfZero(label string) {
+	print("pop rax\n")
+	print("cmp rax, 0\n")
+	print("jz " + label + "\n")
+}
+
+func genJump(label string) {
+	print("jmp " + label + "\n")
+}
+
+// Call panic routine panicFunc with the source position of line srcLine in
+// r10 and r11 unless the condition for jump instruction jumpOK holds.
+func genTrap(jumpOK string, panicFunc string, srcLine int) {
+	okLabel := newLabel()
+	pos := srcPos(srcLine)
+	print(jumpOK + " " + okLabel + "\n")
+	print("mov r10, str" + itoa(strIndex(pos)) + "\n")
+	print("mov r11, " + itoa(len(pos)) + "\n")
+	print("call " + panicFunc + "\n")
+	genLabel(okLabel)
+}
+
+// Generate rax / rbx (or rax % rbx if op is tModulo), panicking if rbx is
+// zero. Dividing the most negative integer by -1 wraps around like Go does
+// rather than faulting.
+func genDivide(op int, unsigned bool, srcLine int) {
+	print("test rbx, rbx\n")
+	genTrap("jnz", "_panicDivide", srcLine)
+	if unsigned {
+		print("xor rdx, rdx\n")
+		print("div rbx\n")
+	} else {
+		divLabel := newLabel()
+		doneLabel := newLabel()
+		print("cmp rbx, -1\n")
+		print("jne " + divLabel + "\n")
+		print("neg rax\n")
+		print("xor rdx, rdx\n") // remainder is 0
+		genJump(doneLabel)
+		genLabel(divLabel)
+		print("cqo\n")
+		print("idiv rbx\n")
+		genLabel(doneLabel)
+	}
+	if op == tModulo {
+		print("mov rax, rdx\n")
+	}
+}
+
+// Trap signed overflow of the +, - or * just performed on rax (if checked
+// arithmetic is enabled). Sized types overflow if truncation changes rax.
+func genCheckOverflow(typ int, srcLine int) {
+	if checkOverflow == 0 || isUnsigned(typ) {
+		return
+	}
+	under := underType(typ)
+	if under == typeInt || under == typeInt64 {
+		genTrap("jno", "_panicOverflow", srcLine)
+		return
+	}
+	print("mov rcx, rax\n")
+	genTruncate(typ)
+	print("cmp rax, rcx\n")
+	genTrap("je", "_panicOverflow", srcLine)
+}
+
+func genBinaryInt(op int, typ int, srcLine int) int {
+	print("pop rbx\n")
+	print("pop rax\n")
+	unsigned := isUnsigned(typ)
+	if op == tPlus {
+		print("add rax, rbx\n")
+		genCheckOverflow(typ, srcLine)
+	} else if op == tMinus {
+		print("sub rax, rbx\n")
+		genCheckOverflow(typ, srcLine)
+	} else if op == tTimes {
+		print("imul rbx\n")
+		genCheckOverflow(typ, srcLine)
+	} else if op == tDivide || op == tModulo {
+		genDivide(op, unsigned, srcLine)
+	} else if op == tEq {
+		genCompareInt("sete")
+	} else if op == tNotEq {
+		genCompareInt("setne")
+	} else if op == tLess && unsigned {
+		genCompareInt("setb")
+	} else if op == tLess {
+		genCompareInt("setl")
+	} else if op == tLessEq && unsigned {
+		genCompareInt("setbe")
+	} else if op == tLessEq {
+		genCompareInt("setle")
+	} else if op == tGreater && unsigned {
+		genCompareInt("seta")
+	} else if op == tGreater {
+		genCompareInt("setg")
+	} else if op == tGreaterEq && unsigned {
+		genCompareInt("setae")
+	} else if op == tGreaterEq {
+		genCompareInt("setge")
+	} else if op == tAnd {
+		print("and rax, rbx\n")
+	} else if op == tOr {
+		print("or rax, rbx\n")
+	}
+	if isComparison(op) {
+		print("push rax\n")
+		return typeBool
+	}
+	genTruncate(typ) // wrap around
+	print("push rax\n")
+	return typ
+}
+
+// Generate a float64 comparison of xmm0 and xmm1 (or xmm1 and xmm0 if
+// reverse is true) using set instruction setInstr.
+func genCompareFloat(setInstr string, reverse bool) {
+	if reverse {
+		print("ucomisd xmm1, xmm0\n")
+	} else {
+		print("ucomisd xmm0, xmm1\n")
+	}
+	print("mov rax, 0\n")
+	print(setInstr + " al\n")
+}
+
+func genBinaryFloat(op int, typ int) int {
+	if op == tModulo || op == tAnd || op == tOr {
+		error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+	}
+	print("movsd xmm1, [rsp]\n")
+	print("movsd xmm0, [rsp+8]\n")
+	print("add rsp, 16\n")
+	if op == tPlus {
+		print("addsd xmm0, xmm1\n")
+	} else if op == tMinus {
+		print("subsd xmm0, xmm1\n")
+	} else if op == tTimes {
+		print("mulsd xmm0, xmm1\n")
+	} else if op == tDivide {
+		print("divsd xmm0, xmm1\n")
+	} else if op == tEq {
+		// Unordered (NaN) operands compare not equal
+		genCompareFloat("sete", false)
+		print("mov rbx, 0\n")
+		print("setnp bl\n")
+		print("and rax, rbx\n")
+	} else if op == tNotEq {
+		genCompareFloat("setne", false)
+		print("mov rbx, 0\n")
+		print("setp bl\n")
+		print("or rax, rbx\n")
+	} else if op == tLess {
+		// Use "above" conditions, which are false for unordered operands
+		genCompareFloat("seta", true)
+	} else if op == tLessEq {
+		genCompareFloat("setae", true)
+	} else if op == tGreater {
+		genCompareFloat("seta", false)
+	} else {
+		genCompareFloat("setae", false)
+	}
+	if isComparison(op) {
+		print("push rax\n")
+		return typeBool
+	}
+	print("movq rax, xmm0\n")
+	print("push rax\n")
+	return typ
+}
+
+// Compare the any or slice of type typ on the stack with nil: an any is nil
+// if its type tag is 0, and a slice if its address is 0.
+func genCompareNil(op int, typ int, nilOnRight bool) int {
+	if op != tEq && op != tNotEq {
+		error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+	}
+	if nilOnRight {
+		print("pop rax\n") // nil
+	}
+	if underType(typ) == typeAny {
+		print("pop rax\n") // value
+		print("pop rax\n") // type tag
+	} else {
+		print("pop rax\n")     // address
+		print("add rsp, 16\n") // length and capacity
+	}
+	if !nilOnRight {
+		print("pop rbx\n") // nil
+	}
+	print("cmp rax, 0\n")
+	print("mov rax, 0\n")
+	if op == tEq {
+		print("sete al\n")
+	} else {
+		print("setne al\n")
+	}
+	print("push rax\n")
+	return typeBool
+}
+
+// Check binary operator op on values of type parameter typ, which must be
+// defined on every type in its type set. Generic function bodies are only
+// compiled like this to check them (see GenericDecl), so no code is needed
+// beyond keeping the stack balanced.
+func genBinaryParam(op int, typ int) int {
+	prop := propNumeric
+	if op == tEq || op == tNotEq {
+		prop = propComparable
+	} else if isComparison(op) || op == tPlus {
+		prop = propOrdered
+	} else if op == tModulo {
+		prop = propInteger
+	}
+	if op == tAnd || op == tOr || !hasProp(typ, prop) {
+		error("operator " + tokenName(op) + " not defined on " + typeName(typ))
+	}
+	print("pop rax\n")
+	if isComparison(op) {
+		return typeBool
+	}
+	return typ
+}
+
+func genBinary(op int, typ1 int, typ2 int, srcLine int) int {
+	if typ2 == typeNil && canBeNil(typ1) {
+		if typeSize(typ1) != 8 {
+			return genCompareNil(op, typ1, true)
+		}
+		typ2 = typ1 // nil is a zero pointer, func or error
+	} else if typ1 == typeNil && canBeNil(typ2) {
+		if typeSize(typ2) != 8 {
+			return genCompareNil(op, typ2, false)
+		}
+		typ1 = typ2
+	}
+	if typ1 != typ2 {
+		error("mismatched types " + typeName(typ1) + " and " + typeName(typ2))
+	}
+	if typeKinds[typ1] == kindParam {
+		return genBinaryParam(op, typ1)
+	}
+	under := underType(typ1)
+	if under == typeString {
+		return genBinaryString(op, typ1)
+	} else if isInteger(typ1) {
+		if op != tAnd && op != tOr {
+			return genBinaryInt(op, typ1, srcLine)
+		}
+	} else if under == typeFloat {
+		return genBinaryFloat(op, typ1)
+	} else if under == typeBool {
+		if op == tAnd || op == tOr || op == tEq || op == tNotEq {
+			return genBinaryInt(op, typ1, srcLine)
+		}
+	} else if under == typeError || isPointer(typ1) || typeKinds[under] == kindFunc {
+		if op == tEq || op == tNotEq {
+			return genBinaryInt(op, typ1, srcLine)
+		}
+	}
+	error("operator " + tokenName(op) + " not defined on " + typeName(typ1))
+	return 0
+}
+
+func genReturn(typ int) {
+	i := 0
+	for i < typeSize(typ)/8 {
+		print("pop " + resultReg(i) + "\n")
+		i = i + 1
+	}
+	genFuncEnd()
+}
+
+// Push a copy of the int on top of the stack.
+func genDup() {
+	print("push qword [rsp]\n")
+}
+
+func genDiscard(typ int) {
+	size := typeSize(typ)
+	if size > 0 {
+		print("add rsp, " + itoa(typeSize(typ)) + "\n")
+	}
+}
+
+// Start a "range" loop over the string on top of the stack (loop state is
+// kept on the stack: the string, and the index of the next rune).
+func genRangeStart() {
+	print("push qword 0\n")
+}
+
+// Generate the test at the top of a "range" loop, jumping to doneLabel if
+// it's done, and otherwise decoding the next rune and pushing it and its
+// index.
+func genRangeNext(doneLabel string) {
+	print("mov rax, [rsp]\n")
+	print("cmp rax, [rsp+16]\n")
+	print("jge " + doneLabel + "\n")
+	print("call _decodeRune\n")
+	print("mov rcx, [rsp]\n")
+	print("add [rsp], rbx\n") // advance to next rune
+	print("push rax\n")       // rune
+	print("push rcx\n")       // index
+}
+
+func genRangeEnd() {
+	print("add rsp, 24\n") // discard loop state
+}
+
+func genSliceExpr(srcLine int) {
+	// Slice expression of form slice[:max]
+	print("pop rax\n") // max
+	print("pop rbx\n") // addr
+	print("pop rcx\n") // old length (capacity remains same)
+	genBoundsCheck("rax", "[rsp]", "jbe", "_panicSlice", srcLine)
+	print("push rax\n") // new length
+	print("push rbx\n") // addr remains same
+}
+
+func genSliceFetch(typ int, srcLine int) int {
+	if underType(typ) == typeString {
+		print("pop rax\n") // index
+		print("pop rbx\n") // addr
+		print("pop rcx\n") // len
+		genBoundsCheck("rax", "rcx", "jb", "_panicIndex", srcLine)
+		print("movzx edx, byte [rbx+rax]\n")
+		print("push rdx\n")
+		return typeUint8
+	} else if !isSlice(typ) {
+		error("invalid slice type " + typeName(typ))
+	}
+	elem := elemType(typ)
+	print("pop rax\n") // index
+	print("pop rbx\n") // addr
+	print("pop rcx\n") // len
+	print("pop rdx\n") // cap
+	genBoundsCheck("rax", "rcx", "jb", "_panicIndex", srcLine)
+	if isByteSlice(typ) {
+		print("movzx edx, byte [rbx+rax]\n")
+		print("push rdx\n")
+		return elem
+	}
+	if typeSize(elem) == 16 {
+		print("add rax, rax\n")
+		print("push qword [rbx+rax*8+8]\n")
+	}
+	print("push qword [rbx+rax*8]\n")
+	return elem
+}
+
+// Insert a nil slice of type typ underneath the value of type elem on top of
+// the stack.
+func genNilSliceUnder(typ int, elem int) {
+	print("pop rax\n")
+	if typeSize(elem) == 16 {
+		print("pop rbx\n")
+	}
+	genZero(typ)
+	if typeSize(elem) == 16 {
+		print("push rbx\n")
+	}
+	print("push rax\n")
+}
+
+// Convert the value of type typ on top of the stack to an any: a value (or a
+// pointer to a copy of a string) and a type tag, with tag 0 for nil.
+func genToAny(typ int) {
+	if typ == typeNil {
+		print("push qword 0\n")
+		return
+	}
+	under := underType(typ)
+	if under == typeString {
+		print("call _boxString\n")
+	} else if isNumeric(typ) || under == typeBool || under == typeError || isPointer(typ) ||
+		typeKinds[typ] == kindParam {
+		print("pop rax\n")
+	} else {
+		error("cannot use " + typeName(typ) + " as any value")
+	}
+	print("mov rbx, " + itoa(under) + "\n")
+	if under == typeError {
+		print("test rax, rax\n")
+		print("cmovz rbx, rax\n") // nil error is a nil any
+	}
+	print("push rbx\n")
+	print("push rax\n")
+}
+
+// Convert the int at offset bytes from the top of the stack to a float64.
+func genIntToFloat(offset int) {
+	print("cvtsi2sd xmm0, qword [rsp+" + itoa(offset) + "]\n")
+	print("movsd [rsp+" + itoa(offset) + "], xmm0\n")
+}
+
+// Convert the untyped constant at offset bytes from the top of the stack to
+// type want if it's numeric, or to its default type if not, and return the
+// resulting type.
+func convertConst(offset int, kind int, neg bool, digits string, exp int, want int) int {
+	if typeKinds[want] == kindParam && hasProp(want, propNumeric) {
+		// Check that it fits every type in the type set (see genBinaryParam)
+		i := typeElems[want] - 1
+		for unionTypes[i] != 0 {
+			term := unionTypes[i]
+			if term < 0 {
+				term = -term
+			}
+			if isInteger(term) {
+				constBits(neg, digits, exp, term)
+			}
+			i = i + 1
+		}
+		return want
+	}
+	if !isNumeric(want) {
+		want = kind
+	}
+	if underType(want) == typeFloat {
+		if kind != typeFloat {
+			print("mov rax, [flt" + itoa(floatIndex(neg, digits, exp)) + "]\n")
+			print("mov [rsp+" + itoa(offset) + "], rax\n")
+		}
+		return want
+	}
+	n := constBits(neg, digits, exp, want)
+	if kind == typeFloat || len(digits)+exp > 18 {
+		print("mov rax, " + itoa(n) + "\n")
+		print("mov [rsp+" + itoa(offset) + "], rax\n")
+	}
+	return want
+}
 
-	// Return string length
-	print("len:\n")
-	print("push rbp\n") // rbp ret addr len
-	print("mov rbp, rsp\n")
-	print("mov rax, [rbp+24]\n")
-	print("pop rbp\n")
-	print("ret 16\n")
-	print("\n")
+// If the value on top of the stack is an untyped constant, convert it to
+// type want as for convertConst. Return the resulting type.
+func convertUntyped(typ int, want int) int {
+	if untypedKind == 0 {
+		return typ
+	}
+	kind := untypedKind
+	untypedKind = 0
+	return convertConst(0, kind, untypedNeg, untypedDigits, untypedExp, want)
+}
 
-	// Return slice length
-	print("_lenSlice:\n")
-	print("push rbp\n") // rbp ret addr len cap
-	print("mov rbp, rsp\n")
-	print("mov rax, [rbp+24]\n")
-	print("pop rbp\n")
-	print("ret 24\n")
-	print("\n")
+// Check that a value of type typ can be assigned to type want, converting
+// the value on top of the stack if needed (including untyped constants).
+// Context describes the assignment for error messages.
+func genAssignable(typ int, want int, context string) {
+	typ = convertUntyped(typ, want)
+	if typ == want {
+		return
+	}
+	if want == typeAny {
+		genToAny(typ)
+		return
+	}
+	if typ == typeNil && canBeNil(want) {
+		genNil(want)
+		return
+	}
+	if underType(typ) == underType(want) {
+		if typeKinds[typ] != kindNamed || typeKinds[want] != kindNamed {
+			return
+		}
+	}
+	error("cannot use " + typeName(typ) + " as " + typeName(want) + " value in " + context)
 }
 
-func genConst(name string, value int) {
-	print(name + " equ " + itoa(value) + "\n")
+// Push the value of type typ held by an any whose value word is in rax.
+func genUnbox(typ int) {
+	if underType(typ) == typeString {
+		print("push qword [rax+8]\n")
+		print("push qword [rax]\n")
+	} else {
+		print("push rax\n")
+	}
 }
 
-func genIntLit(n int) {
-	print("push qword " + itoa(n) + "\n")
+// Pop the any value on the stack into rax (value) and rbx (type tag), and
+// compare its tag with type typ's.
+func genCheckTag(typ int) {
+	under := underType(typ)
+	if under != typeString && !isNumeric(typ) && under != typeBool &&
+		under != typeError && !isPointer(typ) {
+		error("impossible type assertion: any can't hold " + typeName(typ))
+	}
+	print("pop rax\n") // value
+	print("pop rbx\n") // type tag
+	print("cmp rbx, " + itoa(under) + "\n")
 }
 
-func genStrLit(s string) {
-	// Add string to strs and strAddrs tables
-	index := find(strs, s)
-	if index < 0 {
-		// Haven't seen this string constant before, add a new one
-		index = len(strs)
-		strs = append(strs, s)
+// Generate a type assertion of the any value on the stack to type typ,
+// which panics if it holds a different type.
+func genAssert(typ int) {
+	okLabel := newLabel()
+	genCheckTag(typ)
+	print("je " + okLabel + "\n")
+	print("mov rcx, " + itoa(underType(typ)) + "\n")
+	print("call _panicAssert\n")
+	genLabel(okLabel)
+	genUnbox(typ)
+}
+
+// Generate a "v, ok" type assertion of the any value on the stack to type
+// typ, which yields the zero value and false if it holds a different type.
+func genAssertOk(typ int) int {
+	failLabel := newLabel()
+	doneLabel := newLabel()
+	genCheckTag(typ)
+	print("jne " + failLabel + "\n")
+	genUnbox(typ)
+	print("push qword 1\n")
+	genJump(doneLabel)
+	genLabel(failLabel)
+	genZero(typ)
+	print("push qword 0\n")
+	genLabel(doneLabel)
+	start := len(tupleTypes)
+	tupleTypes = append(tupleTypes, 2, typ, typeBool)
+	return tupleType(start)
+}
+
+// Append value of type valueType to the slice of type typ below it on the stack.
+func genAppendValue(typ int, valueType int) {
+	elem := elemType(typ)
+	genAssignable(valueType, elem, "argument to append")
+	if typeSize(elem) == 16 {
+		genCall("_appendString")
+	} else if isByteSlice(typ) {
+		genCall("_appendByte")
+	} else {
+		genCall("_appendInt")
 	}
-	// Push string struct: length and then address (by label)
-	print("push qword " + itoa(len(s)) + "\n")
-	print("push qword str" + itoa(index) + "\n")
 }
 
-func typeName(typ int) string {
-	return types[typ]
+// Replace the string on top of the stack with a []byte sharing its bytes.
+func genStringAsSlice() {
+	print("pop rax\n")  // addr
+	print("pop rbx\n")  // len
+	print("push rbx\n") // cap
+	print("push rbx\n") // len
+	print("push rax\n") // addr
 }
 
-func typeSize(typ int) int {
-	return typeSizes[typ]
+// Append all elements of the slice on top of the stack to the slice below it
+// (or the bytes of a string to a []byte).
+func genAppendSlice(typ int, valueType int) {
+	if isByteSlice(typ) && underType(valueType) == typeString {
+		genStringAsSlice()
+		valueType = typ
+	}
+	genAssignable(valueType, typ, "argument to append")
+	if typeSize(elemType(typ)) == 16 {
+		genCall("_appendStrings")
+	} else if isByteSlice(typ) {
+		genCall("_appendBytes")
+	} else {
+		genCall("_appendInts")
+	}
 }
 
-// Return offset of local variable from rbp (including arguments).
-func localOffset(index int) int {
-	funcIndex := find(funcs, curFunc)
-	sigIndex := funcSigIndexes[funcIndex]
-	numArgs := funcSigs[sigIndex+1]
-	if index < numArgs {
-		// Function argument local (add to rbp; args are on stack in reverse)
-		offset := 16
-		i := numArgs - 1
-		for i > index {
-			offset = offset + typeSize(localTypes[i])
-			i = i - 1
+// Recursive-descent parser
+
+func expect(expected int, msg string) {
+	if token != expected {
+		error("expected " + msg + " not " + tokenName(token))
+	}
+	next()
+}
+
+func Literal() int {
+	if token == tIntLit || token == tCharLit {
+		// Character literals are untyped constants of default type rune
+		untypedKind = typeInt
+		if token == tCharLit {
+			untypedKind = typeInt32
 		}
-		return offset
-	} else {
-		// Declared local (subtract from rbp)
-		offset := 0
-		i := numArgs
-		for i <= index {
-			offset = offset - typeSize(localTypes[i])
-			i = i + 1
+		untypedNeg = false
+		untypedDigits = tokenStr
+		if tokenStr[0] == '0' {
+			untypedDigits = natTrim(tokenStr)
 		}
-		return offset
+		untypedExp = 0
+		genIntLit(tokenInt)
+		next()
+		return untypedKind
+	} else if token == tFloatLit {
+		untypedKind = typeFloat
+		untypedNeg, untypedDigits, untypedExp = decNorm(false, tokenStr, tokenInt)
+		genUntyped()
+		next()
+		return typeFloat
+	} else if token == tStrLit {
+		untypedKind = 0
+		genStrLit(tokenStr)
+		next()
+		return typeString
+	} else {
+		error("expected integer, floating-point, character or string literal")
+		return 0
 	}
 }
 
-func genFetchInstrs(typ int, addr string) {
-	if typ == typeInt {
-		print("push qword [" + addr + "]\n")
-	} else if typ == typeString {
-		print("push qword [" + addr + "+8]\n")
-		print("push qword [" + addr + "]\n")
-	} else { // slice
-		print("push qword [" + addr + "+16]\n")
-		print("push qword [" + addr + "+8]\n")
-		print("push qword [" + addr + "]\n")
+func identifier(msg string) {
+	expect(tIdent, msg)
+}
+
+// Parse values to append to the slice of type typ on the stack (the first
+// value, of type valueType, has already been parsed and pushed).
+func appendValues(typ int, valueType int) {
+	genAppendValue(typ, valueType)
+	for token == tComma {
+		next()
+		if token == tRParen {
+			return // trailing comma
+		}
+		genAppendValue(typ, Expression())
 	}
 }
 
-func genLocalFetch(index int) int {
-	offset := localOffset(index)
-	typ := localTypes[index]
-	genFetchInstrs(typ, "rbp+"+itoa(offset))
-	return typ
+// Parse the rest of the arguments to a variadic parameter of slice type typ,
+// after the first value (of type valueType): either more values to collect
+// into a new slice, or "..." if it's an existing slice to pass as is.
+func variadicRest(typ int, valueType int) {
+	if token == tEllipsis {
+		next()
+		genAssignable(valueType, typ, "variadic argument")
+		if token == tComma {
+			next()
+		}
+		return
+	}
+	elem := elemType(typ)
+	genAssignable(valueType, elem, "variadic argument")
+	genNilSliceUnder(typ, elem)
+	appendValues(typ, elem)
 }
 
-func genGlobalFetch(index int) int {
-	name := globals[index]
-	typ := globalTypes[index]
-	genFetchInstrs(typ, name)
-	return typ
+// Parse arguments to the variadic parameter of slice type typ: either a list
+// of values to collect into a new slice, or an existing slice followed by
+// "...", which is passed through as is.
+func variadicArgs(typ int) {
+	if token == tRParen {
+		genZero(typ)
+		return
+	}
+	variadicRest(typ, Expression())
 }
 
-func genConstFetch(index int) int {
-	name := consts[index]
-	print("push qword " + name + "\n")
-	return typeInt
+// Parse the arguments to funcName (after the "("), starting with parameter
+// first (earlier ones, such as a method's receiver, have already been
+// pushed), converting each to its parameter's type.
+func callArgs(funcName string, first int) int {
+	index := find(funcs, funcName)
+	if index < 0 {
+		error("can't call non-function " + escape(funcName, "\""))
+	}
+	sigIndex := funcSigIndexes[index]
+	numFixed := funcSigs[sigIndex+1]
+	if funcVariadics[index] != 0 {
+		numFixed = numFixed - 1
+	}
+	i := first
+	for i < numFixed && token != tRParen {
+		typ := Expression()
+		genAssignable(typ, funcSigs[sigIndex+2+i], "argument to "+funcName)
+		i = i + 1
+		if token != tRParen {
+			expect(tComma, ",")
+		}
+	}
+	if i < numFixed {
+		error("not enough arguments in call to " + funcName)
+	}
+	if funcVariadics[index] != 0 {
+		variadicArgs(funcSigs[sigIndex+2+numFixed])
+	} else if token != tRParen {
+		error("too many arguments in call to " + funcName)
+	}
+	expect(tRParen, ")")
+	return genCall(funcName)
 }
 
-func genIdentifier(name string) int {
-	localIndex := find(locals, name)
-	if localIndex >= 0 {
-		return genLocalFetch(localIndex)
+// Parse a call of the function value in variable name (after the name),
+// which is called through its address.
+func valueCall(name string) int {
+	typ := underType(varType(name))
+	if typeKinds[typ] != kindFunc {
+		error("invalid operation: cannot call non-function " + name)
 	}
-	globalIndex := find(globals, name)
-	if globalIndex >= 0 {
-		return genGlobalFetch(globalIndex)
+	sig := typeElems[typ]
+	expect(tLParen, "(")
+	i := 0
+	for i < funcTypeSigs[sig+1] && token != tRParen {
+		genAssignable(Expression(), funcTypeSigs[sig+2+i], "argument to "+name)
+		i = i + 1
+		if token != tRParen {
+			expect(tComma, ",")
+		}
 	}
-	constIndex := find(consts, name)
-	if constIndex >= 0 {
-		return genConstFetch(constIndex)
+	if i < funcTypeSigs[sig+1] {
+		error("not enough arguments in call to " + name)
 	}
-	funcIndex := find(funcs, name)
-	if funcIndex >= 0 {
-		sigIndex := funcSigIndexes[funcIndex]
-		return funcSigs[sigIndex] // result type
+	expect(tRParen, ")")
+	genIdentifier(name)
+	print("pop rax\n")
+	return genCallType("rax", funcTypeSigs[sig])
+}
+
+// Report whether type typ uses any type parameters.
+func hasTypeParam(typ int) bool {
+	kind := typeKinds[typ]
+	i := 0
+	if kind == kindParam {
+		return true
+	} else if kind == kindSlice || kind == kindPointer || kind == kindNamed {
+		return hasTypeParam(typeElems[typ])
+	} else if kind == kindFunc {
+		sig := typeElems[typ]
+		if hasTypeParam(funcTypeSigs[sig]) {
+			return true
+		}
+		for i < funcTypeSigs[sig+1] {
+			if hasTypeParam(funcTypeSigs[sig+2+i]) {
+				return true
+			}
+			i = i + 1
+		}
+	} else if kind == kindTuple {
+		for i < tupleLen(typ) {
+			if hasTypeParam(tupleElem(typ, i)) {
+				return true
+			}
+			i = i + 1
+		}
 	}
-	error("identifier " + escape(name, "\"") + " not defined")
-	return 0
+	return false
 }
 
-func genAssignInstrs(typ int, addr string) {
-	if typ == typeInt {
-		print("pop qword [" + addr + "]\n")
-	} else if typ == typeString {
-		print("pop qword [" + addr + "]\n")
-		print("pop qword [" + addr + "+8]\n")
-	} else { // slice
-		print("pop qword [" + addr + "]\n")
-		print("pop qword [" + addr + "+8]\n")
-		print("pop qword [" + addr + "+16]\n")
+// Return func type typ with type parameters replaced as for subst.
+func substSig(typ int, start int, n int, base int) int {
+	sig := typeElems[typ]
+	sigStart := len(sigStack)
+	sigStack = append(sigStack, typeVoid, funcTypeSigs[sig+1])
+	t := subst(funcTypeSigs[sig], start, n, base)
+	sigStack[sigStart] = t
+	i := 0
+	for i < funcTypeSigs[sig+1] {
+		t = subst(funcTypeSigs[sig+2+i], start, n, base)
+		sigStack = append(sigStack, t)
+		i = i + 1
 	}
+	return funcType(sigStart)
 }
 
-func genLocalAssign(index int) {
-	offset := localOffset(index)
-	genAssignInstrs(localTypes[index], "rbp+"+itoa(offset))
+// Return tuple type typ with type parameters replaced as for subst.
+func substTuple(typ int, start int, n int, base int) int {
+	count := tupleLen(typ)
+	temp := len(sigStack)
+	i := 0
+	for i < count {
+		t := subst(tupleElem(typ, i), start, n, base)
+		sigStack = append(sigStack, t)
+		i = i + 1
+	}
+	tupleStart := len(tupleTypes)
+	tupleTypes = append(tupleTypes, count)
+	i = 0
+	for i < count {
+		tupleTypes = append(tupleTypes, sigStack[temp+i])
+		i = i + 1
+	}
+	sigStack = sigStack[:temp]
+	return tupleType(tupleStart)
 }
 
-func genGlobalAssign(index int) {
-	name := globals[index]
-	genAssignInstrs(globalTypes[index], name)
+// Infer type arguments of generic function g (at index base of
+// callTypeArgs) by matching parameter type param with argument type arg.
+func unify(g int, base int, param int, arg int) {
+	kind := typeKinds[param]
+	i := findInt(typeParamTypes, param) - genericParams[g]
+	if kind == kindParam && i >= 0 && i < genericNumParams[g] && arg != typeNil {
+		if callTypeArgs[base+i] == 0 {
+			callTypeArgs[base+i] = arg
+		}
+	} else if kind == kindSlice && isSlice(arg) || kind == kindPointer && isPointer(arg) {
+		unify(g, base, typeElems[param], elemType(arg))
+	} else if kind == kindFunc && typeKinds[underType(arg)] == kindFunc {
+		p := typeElems[param]
+		a := typeElems[underType(arg)]
+		if funcTypeSigs[p+1] == funcTypeSigs[a+1] {
+			unify(g, base, funcTypeSigs[p], funcTypeSigs[a])
+			i = 0
+			for i < funcTypeSigs[p+1] {
+				unify(g, base, funcTypeSigs[p+2+i], funcTypeSigs[a+2+i])
+				i = i + 1
+			}
+		}
+	} else if kind == kindNamed {
+		k := findInt(typeInsts, param)
+		m := findInt(typeInsts, arg)
+		if k >= 0 && m >= 0 {
+			if typeInstGenerics[k] == typeInstGenerics[m] {
+				i = 0
+				for i < genericTypeNumParams[typeInstGenerics[k]] {
+					unify(g, base, instanceTypeArgs[typeInstArgs[k]+i],
+						instanceTypeArgs[typeInstArgs[m]+i])
+					i = i + 1
+				}
+			}
+		} else if k >= 0 {
+			unify(g, base, typeElems[param], underType(arg))
+		}
+	}
 }
 
-func genAssign(name string) {
-	localIndex := find(locals, name)
-	if localIndex >= 0 {
-		genLocalAssign(localIndex)
-		return
+// Return constraint c (see typeParamTypes) as it's written, such as
+// "~int | float64".
+func constraintName(c int) string {
+	if c == 0 {
+		return "any"
+	} else if c < 0 {
+		return "comparable"
 	}
-	globalIndex := find(globals, name)
-	if globalIndex >= 0 {
-		genGlobalAssign(globalIndex)
-		return
+	name := ""
+	i := c - 1
+	for unionTypes[i] != 0 {
+		if name != "" {
+			name = name + " | "
+		}
+		term := unionTypes[i]
+		if term < 0 {
+			name = name + "~"
+			term = -term
+		}
+		name = name + typeName(term)
+		i = i + 1
 	}
-	error("identifier " + escape(name, "\"") + " not defined (or not assignable)")
+	return name
 }
 
-func varType(name string) int {
-	localIndex := find(locals, name)
-	if localIndex >= 0 {
-		return localTypes[localIndex]
+// Report whether type typ satisfies constraint c, whose union terms may use
+// the type parameters typeParamTypes[start:start+n] (bound to the type
+// arguments at index base of callTypeArgs). A type parameter satisfies it if
+// every type in its own type set does.
+func satisfies(typ int, c int, start int, n int, base int) bool {
+	if c == 0 {
+		return true
 	}
-	globalIndex := find(globals, name)
-	if globalIndex >= 0 {
-		return globalTypes[globalIndex]
+	i := 0
+	term := 0
+	if typeKinds[typ] == kindParam {
+		if typeElems[typ] <= 0 {
+			return typeElems[typ] < 0 && c < 0
+		}
+		i = typeElems[typ] - 1
+		for unionTypes[i] != 0 {
+			term = unionTypes[i]
+			if term < 0 {
+				term = -term
+			}
+			if !satisfies(term, c, start, n, base) {
+				return false
+			}
+			i = i + 1
+		}
+		return true
 	}
-	error("identifier " + escape(name, "\"") + " not defined")
-	return 0
+	if c < 0 {
+		return hasProp(typ, propComparable)
+	}
+	i = c - 1
+	for unionTypes[i] != 0 {
+		term = unionTypes[i]
+		if term < 0 {
+			if underType(typ) == underType(subst(-term, start, n, base)) {
+				return true
+			}
+		} else if typ == subst(term, start, n, base) {
+			return true
+		}
+		i = i + 1
+	}
+	return false
 }
 
-func genSliceAssign(name string) {
-	typ := varType(name)
-	print("pop rax\n") // value (addr if string type)
-	if typ == typeSliceStr {
-		print("pop rbx\n") // value (len)
-		print("pop rcx\n") // index * 2
-		print("add rcx, rcx\n")
-	} else {
-		print("pop rcx\n")
+// Check that type argument typ satisfies the constraint of type parameter
+// param (see satisfies).
+func checkConstraint(typ int, param int, start int, n int, base int) {
+	c := typeElems[param]
+	if !satisfies(typ, c, start, n, base) {
+		error(typeName(typ) + " does not satisfy " + constraintName(c))
 	}
-	localIndex := find(locals, name)
-	if localIndex >= 0 {
-		offset := localOffset(localIndex)
-		print("mov rdx, [rbp+" + itoa(offset) + "]\n")
-	} else {
-		print("mov rdx, [" + name + "]\n")
+}
+
+// Return the instance of generic type d for the type arguments at index base
+// of callTypeArgs (after checking them against the constraints), a named type
+// like "List[int]" with the type parameters replaced in its underlying type.
+func instantiateType(d int, base int) int {
+	start := genericTypeParams[d]
+	n := genericTypeNumParams[d]
+	name := genericTypes[d] + "["
+	i := 0
+	for i < n {
+		if i > 0 {
+			name = name + ","
+		}
+		checkConstraint(callTypeArgs[base+i], typeParamTypes[start+i], start, n, base)
+		name = name + typeName(callTypeArgs[base+i])
+		i = i + 1
 	}
-	print("mov [rdx+rcx*8], rax\n")
-	if typ == typeSliceStr {
-		print("mov [rdx+rcx*8+8], rbx\n")
+	name = name + "]"
+	under := subst(genericTypeUnders[d], start, n, base)
+	typ := derivedType(name, typeSize(under), kindNamed, under)
+	if findInt(typeInsts, typ) < 0 {
+		typeInsts = append(typeInsts, typ)
+		typeInstGenerics = append(typeInstGenerics, d)
+		typeInstArgs = append(typeInstArgs, len(instanceTypeArgs))
+		i = 0
+		for i < n {
+			instanceTypeArgs = append(instanceTypeArgs, callTypeArgs[base+i])
+			i = i + 1
+		}
 	}
+	return typ
 }
 
-func genCall(name string) int {
-	print("call " + name + "\n")
-	index := find(funcs, name)
-	sigIndex := funcSigIndexes[index]
-	resultType := funcSigs[sigIndex]
-	if resultType == typeInt {
-		print("push rax\n")
-	} else if resultType == typeString {
-		print("push rbx\n")
-		print("push rax\n")
-	} else if resultType == typeSliceInt || resultType == typeSliceStr {
-		print("push rcx\n")
-		print("push rbx\n")
-		print("push rax\n")
+// Return typ, an instance of a generic type, with type parameters replaced
+// in its type arguments as for subst.
+func substInst(typ int, start int, n int, base int) int {
+	k := findInt(typeInsts, typ)
+	d := typeInstGenerics[k]
+	argBase := len(callTypeArgs)
+	t := 0
+	i := 0
+	for i < genericTypeNumParams[d] {
+		t = subst(instanceTypeArgs[typeInstArgs[k]+i], start, n, base)
+		callTypeArgs = append(callTypeArgs, t)
+		i = i + 1
 	}
-	return resultType
+	t = instantiateType(d, argBase)
+	callTypeArgs = callTypeArgs[:argBase]
+	return t
 }
 
-func genFuncStart(name string) {
-	print("\n")
-	print(name + ":\n")
-	print("push rbp\n")
-	print("mov rbp, rsp\n")
-	print("sub rsp, " + itoa(localSpace) + "\n") // space for locals
+// Return type typ with the type parameters typeParamTypes[start:start+n]
+// replaced by the type arguments at index base of callTypeArgs.
+func subst(typ int, start int, n int, base int) int {
+	kind := typeKinds[typ]
+	i := findInt(typeParamTypes, typ) - start
+	if kind == kindParam && i >= 0 && i < n {
+		if callTypeArgs[base+i] == 0 {
+			error("cannot infer " + typeName(typ))
+		}
+		return callTypeArgs[base+i]
+	} else if kind == kindSlice {
+		return sliceType(subst(typeElems[typ], start, n, base))
+	} else if kind == kindPointer {
+		return pointerType(subst(typeElems[typ], start, n, base))
+	} else if kind == kindFunc {
+		return substSig(typ, start, n, base)
+	} else if kind == kindTuple {
+		return substTuple(typ, start, n, base)
+	} else if kind == kindNamed && findInt(typeInsts, typ) >= 0 {
+		return substInst(typ, start, n, base)
+	}
+	return typ
 }
 
-// Return size (in bytes) of current function's arguments.
-func argsSize() int {
-	i := find(funcs, curFunc)
-	sigIndex := funcSigIndexes[i]
-	numArgs := funcSigs[sigIndex+1]
-	size := 0
-	i = 0
-	for i < numArgs {
-		size = size + typeSize(funcSigs[sigIndex+2+i])
+// Parse the type arguments of generic type d (after its name), like "[int]"
+// in List[int], and return that instance of it.
+func typeInstance(d int) int {
+	base := len(callTypeArgs)
+	n := genericTypeNumParams[d]
+	expect(tLBracket, "[")
+	typ := 0
+	i := 0
+	for i < n {
+		typ = Type()
+		callTypeArgs = append(callTypeArgs, typ)
 		i = i + 1
+		if i < n {
+			expect(tComma, ",")
+		}
 	}
-	return size
+	expect(tRBracket, "]")
+	typ = instantiateType(d, base)
+	callTypeArgs = callTypeArgs[:base]
+	return typ
 }
 
-// Return size (in bytes) of current function's locals (excluding arguments).
-func localsSize() int {
-	i := find(funcs, curFunc)
-	sigIndex := funcSigIndexes[i]
-	numArgs := funcSigs[sigIndex+1]
-	size := 0
-	i = numArgs
-	for i < len(locals) {
-		size = size + typeSize(localTypes[i])
+// Return the name of the instance of generic function g for the type
+// arguments at index base of callTypeArgs (after checking them against the
+// constraints), recording it to be compiled at the end if it's new. While
+// checking a generic function the type arguments may use its type
+// parameters, and then the call is to the generic function's checked code.
+func instantiate(g int, base int) string {
+	start := genericParams[g]
+	n := genericNumParams[g]
+	generic := false
+	i := 0
+	for i < n {
+		typ := callTypeArgs[base+i]
+		if typ == 0 {
+			error("cannot infer " + typeParams[start+i])
+		}
+		checkConstraint(typ, typeParamTypes[start+i], start, n, base)
+		if hasTypeParam(typ) {
+			generic = true
+		}
 		i = i + 1
 	}
-	return size
-}
-
-func genFuncEnd() {
-	size := localsSize()
-	if size > localSpace {
-		error(curFunc + "'s locals too big (" + itoa(size) + " > " + itoa(localSpace) + ")\n")
+	if generic {
+		return generics[g]
 	}
-	print("mov rsp, rbp\n")
-	print("pop rbp\n")
-	size = argsSize()
-	if size > 0 {
-		print("ret " + itoa(size) + "\n")
-	} else {
-		print("ret\n")
+	k := 0
+	for k < len(instances) {
+		if instanceGenerics[k] == g {
+			if equalInts(instanceTypeArgs, instanceArgs[k], callTypeArgs, base, n) {
+				return instances[k]
+			}
+		}
+		k = k + 1
+	}
+	instances = append(instances, generics[g]+"."+itoa(k))
+	instanceGenerics = append(instanceGenerics, g)
+	instanceArgs = append(instanceArgs, len(instanceTypeArgs))
+	i = 0
+	for i < n {
+		instanceTypeArgs = append(instanceTypeArgs, callTypeArgs[base+i])
+		i = i + 1
 	}
+	return instances[k]
 }
 
-func genDataSections() {
-	print("\n")
-	print("section .data\n")
-	print("_strOutOfMem: db `out of memory\\n`\n")
-
-	// String constants
+// Push the type arguments of a call to generic function g onto
+// callTypeArgs: those given explicitly, like "[int]", then 0 for each one
+// to be inferred.
+func typeArguments(g int) {
+	base := len(callTypeArgs)
+	n := genericNumParams[g]
 	i := 0
-	for i < len(strs) {
-		print("str" + itoa(i) + ": db " + escape(strs[i], "`") + "\n")
+	for i < n {
+		callTypeArgs = append(callTypeArgs, 0)
 		i = i + 1
 	}
-
-	// Global variables
-	print("align 8\n")
+	if token != tLBracket {
+		return
+	}
+	next()
 	i = 0
-	for i < len(globals) {
-		typ := globalTypes[i]
-		if typ == typeInt {
-			print(globals[i] + ": dq 0\n")
-		} else if typ == typeString {
-			print(globals[i] + ": dq 0, 0\n") // string: address, length
-		} else {
-			print(globals[i] + ": dq 0, 0, 0\n") // slice: address, length, capacity
+	for token != tRBracket {
+		if i >= n {
+			error("got too many type arguments for " + generics[g])
 		}
+		typ := Type()
+		callTypeArgs[base+i] = typ
 		i = i + 1
+		if token != tRBracket {
+			expect(tComma, ",")
+		}
 	}
+	next()
+}
 
-	// "Heap" (used for strings and slice appends)
-	print("\n")
-	print("section .bss\n")
-	print("_heapPtr: resq 1\n")
-	print("_heap: resb " + itoa(heapSize) + "\n")
-	print("_heapEnd:\n")
+// Parse the arguments to generic function g (after the "("), which is at
+// index in funcs, inferring its type arguments at index base of
+// callTypeArgs from their types.
+func genericArgs(g int, index int, base int) {
+	start := genericParams[g]
+	n := genericNumParams[g]
+	sigIndex := funcSigIndexes[index]
+	numFixed := funcSigs[sigIndex+1]
+	if funcVariadics[index] != 0 {
+		numFixed = numFixed - 1
+	}
+	param := 0
+	typ := 0
+	i := 0
+	for i < numFixed && token != tRParen {
+		param = funcSigs[sigIndex+2+i]
+		typ = Expression()
+		unify(g, base, param, typ)
+		genAssignable(typ, subst(param, start, n, base), "argument to "+generics[g])
+		i = i + 1
+		if token != tRParen {
+			expect(tComma, ",")
+		}
+	}
+	if i < numFixed {
+		error("not enough arguments in call to " + generics[g])
+	}
+	if funcVariadics[index] != 0 && token != tRParen {
+		param = funcSigs[sigIndex+2+numFixed]
+		typ = Expression()
+		if token == tEllipsis {
+			unify(g, base, param, typ)
+		} else {
+			unify(g, base, elemType(param), typ)
+		}
+		variadicRest(subst(param, start, n, base), typ)
+	} else if funcVariadics[index] != 0 {
+		genZero(funcSigs[sigIndex+2+numFixed])
+	} else if token != tRParen {
+		error("too many arguments in call to " + generics[g])
+	}
+	expect(tRParen, ")")
 }
 
-func genUnary(op int, typ int) {
-	if typ != typeInt {
-		error("unary operator not allowed on type " + typeName(typ))
+// Return size in bytes of the elements of slice type typ.
+func elemSize(typ int) int {
+	if isByteSlice(typ) {
+		return 1
 	}
-	print("pop rax\n")
-	if op == tMinus {
-		print("neg rax\n")
-	} else if op == tNot {
-		print("cmp rax, 0\n")
-		print("mov rax, 0\n")
-		print("setz al\n")
+	return typeSize(elemType(typ))
+}
+
+// Parse the rest of the arguments to built-in append, after the slice of
+// type typ: any number of values, or a single slice followed by "...".
+func appendArgs(typ int) int {
+	if token == tComma {
+		next()
+		if token != tRParen {
+			valueType := Expression()
+			if token == tEllipsis {
+				next()
+				genAppendSlice(typ, valueType)
+				if token == tComma {
+					next()
+				}
+			} else {
+				appendValues(typ, valueType)
+			}
+		}
 	}
-	print("push rax\n")
+	expect(tRParen, ")")
+	return typ
 }
 
-func genBinaryString(op int) int {
-	if op == tPlus {
-		print("call _strAdd\n")
-		print("push rbx\n")
-		print("push rax\n")
-		return typeString
-	} else if op == tEq {
-		print("call _strEq\n")
-		print("push rax\n")
-		return typeInt
-	} else if op == tNotEq {
-		print("call _strEq\n")
-		print("cmp rax, 0\n")
-		print("mov rax, 0\n")
-		print("setz al\n")
-		print("push rax\n")
-		return typeInt
-	} else {
-		error("operator " + tokenName(op) + " not allowed on strings")
-		return 0
+// Parse the rest of the arguments to built-in copy, after the destination
+// slice of type typ: a slice with the same element type, or a string if
+// it's a []byte.
+func copyArgs(typ int) int {
+	expect(tComma, ",")
+	srcType := Expression()
+	if isByteSlice(typ) && underType(srcType) == typeString {
+		genStringAsSlice()
+		srcType = typ
 	}
+	if !isSlice(srcType) || elemType(srcType) != elemType(typ) {
+		error("arguments to copy have different element types " + typeName(typ) +
+			" and " + typeName(srcType))
+	}
+	expect(tRParen, ")")
+	genIntLit(elemSize(typ))
+	return genCallType("_copy", typeInt)
 }
 
-func genBinaryInt(op int) int {
-	print("pop rbx\n")
-	print("pop rax\n")
-	if op == tPlus {
-		print("add rax, rbx\n")
-	} else if op == tMinus {
-		print("sub rax, rbx\n")
-	} else if op == tTimes {
-		print("imul rbx\n")
-	} else if op == tDivide {
-		print("cqo\n")
-		print("idiv rbx\n")
-	} else if op == tModulo {
-		print("cqo\n")
-		print("idiv rbx\n")
-		print("mov rax, rdx\n")
-	} else if op == tEq {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("sete al\n")
-	} else if op == tNotEq {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("setne al\n")
-	} else if op == tLess {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("setl al\n")
-	} else if op == tLessEq {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("setle al\n")
-	} else if op == tGreater {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("setg al\n")
-	} else if op == tGreaterEq {
-		print("cmp rax, rbx\n")
-		print("mov rax, 0\n")
-		print("setge al\n")
-	} else if op == tAnd {
-		print("and rax, rbx\n")
-	} else if op == tOr {
-		print("or rax, rbx\n")
+// Parse an integer argument to make (a length or capacity).
+func sizeArg() {
+	if !hasProp(convertUntyped(Expression(), typeInt), propInteger) {
+		error("make size must be integer")
 	}
-	print("push rax\n")
-	return typeInt
 }
 
-func genBinary(op int, typ1 int, typ2 int) int {
-	if typ1 != typ2 {
-		error("binary operands must be the same type")
+// Parse the arguments to built-in make (after the "("), like "[]int, len,
+// cap", binding its type argument (at index base of callTypeArgs) to the
+// element type of the slice type.
+func makeArgs(g int, base int) int {
+	typ := Type()
+	unify(g, base, funcSigs[funcSigIndexes[find(funcs, "make")]], typ)
+	if callTypeArgs[base] == 0 {
+		error("invalid argument: cannot make " + typeName(typ))
 	}
-	if typ1 == typeString {
-		return genBinaryString(op)
+	expect(tComma, ",")
+	sizeArg()
+	if token == tComma {
+		next()
+		sizeArg()
 	} else {
-		return genBinaryInt(op)
+		genDup() // capacity is same as length
 	}
+	expect(tRParen, ")")
+	genIntLit(elemSize(typ))
+	return genCallType("_makeSlice", typ)
 }
 
-func genReturn(typ int) {
-	if typ == typeInt {
-		print("pop rax\n")
-	} else if typ == typeString {
-		print("pop rax\n")
-		print("pop rbx\n")
-	} else if typ == typeSliceInt || typ == typeSliceStr {
-		print("pop rax\n")
-		print("pop rbx\n")
-		print("pop rcx\n")
+// Parse the arguments to built-in generic function g (after the "("),
+// inferring its type argument. The instances are runtime routines chosen
+// by how the elements are stored (see genAppendValue).
+func builtinCall(g int, base int) int {
+	name := generics[g]
+	if name == "make" {
+		return makeArgs(g, base)
 	}
-	genFuncEnd()
+	param := funcSigs[funcSigIndexes[find(funcs, name)]+2]
+	typ := Expression()
+	if name == "len" && underType(typ) == typeString {
+		expect(tRParen, ")")
+		return genCallType("len", typeInt)
+	}
+	unify(g, base, param, typ)
+	if callTypeArgs[base] == 0 {
+		error("invalid argument: " + typeName(typ) + " for built-in " + name)
+	}
+	genAssignable(typ, subst(param, genericParams[g], 1, base), "argument to "+name)
+	if name == "len" || name == "cap" {
+		expect(tRParen, ")")
+		return genCallType("_"+name+"Slice", typeInt)
+	} else if name == "copy" {
+		return copyArgs(typ)
+	}
+	return appendArgs(typ)
 }
 
-func genJumpIfZero(label string) {
-	print("pop rax\n")
-	print("cmp rax, 0\n")
-	print("jz " + label + "\n")
+// Parse a call to generic function g (after its name), with optional type
+// arguments, inferring the rest from the arguments, and call the instance
+// for its type arguments.
+func genericCall(g int) int {
+	base := len(callTypeArgs)
+	typeArguments(g)
+	expect(tLParen, "(")
+	typ := 0
+	if genericStarts[g] < 0 {
+		typ = builtinCall(g, base)
+	} else {
+		index := find(funcs, generics[g])
+		genericArgs(g, index, base)
+		typ = subst(funcSigs[funcSigIndexes[index]], genericParams[g], genericNumParams[g], base)
+		typ = genCallType(instantiate(g, base), typ)
+	}
+	callTypeArgs = callTypeArgs[:base]
+	return typ
 }
 
-func genJump(label string) {
-	print("jmp " + label + "\n")
+func Arguments(funcName string) int {
+	if find(locals, funcName) >= 0 || find(globals, funcName) >= 0 {
+		return valueCall(funcName)
+	}
+	g := find(generics, funcName)
+	if g >= 0 {
+		return genericCall(g)
+	}
+	expect(tLParen, "(")
+	return callArgs(funcName, 0)
 }
 
-func genLabel(label string) {
-	print("\n")
-	print(label + ":\n")
+func isUpper(ch int) bool {
+	return ch >= 'A' && ch <= 'Z'
 }
 
-func genDiscard(typ int) {
-	size := typeSize(typ)
-	if size > 0 {
-		print("add rsp, " + itoa(typeSize(typ)) + "\n")
+// Return name qualified with the current package name if we're not in
+// package main (top-level names in other packages are prefixed with "pkg.").
+func declName(name string) string {
+	if curPackage == "main" {
+		return name
 	}
+	return curPackage + "." + name
 }
 
-func genSliceExpr() {
-	// Slice expression of form slice[:max]
-	print("pop rax\n")  // max
-	print("pop rbx\n")  // addr
-	print("pop rcx\n")  // old length (capacity remains same)
-	print("push rax\n") // new length
-	print("push rbx\n") // addr remains same
+// Return the symbol name that unqualified identifier name refers to.
+func symbolName(name string) string {
+	if curPackage == "main" || find(locals, name) >= 0 {
+		return name
+	}
+	qualified := declName(name)
+	if find(globals, qualified) >= 0 || find(consts, qualified) >= 0 ||
+		find(funcs, qualified) >= 0 || find(types, qualified) >= 0 ||
+		find(genericTypes, qualified) >= 0 {
+		return qualified
+	}
+	return name // built-in
 }
 
-func genSliceFetch(typ int) int {
-	if typ == typeString {
-		print("pop rax\n") // index
-		print("pop rbx\n") // addr
-		print("pop rcx\n") // len
-		print("xor rdx, rdx\n")
-		print("mov dl, [rbx+rax]\n")
-		print("push rdx\n")
-		return typeInt
-	} else if typ == typeSliceInt {
-		print("pop rax\n") // index
-		print("pop rbx\n") // addr
-		print("pop rcx\n") // len
-		print("pop rdx\n") // cap
-		print("push qword [rbx+rax*8]\n")
-		return typeInt
-	} else if typ == typeSliceStr {
-		print("pop rax\n") // index
-		print("pop rbx\n") // addr
-		print("pop rcx\n") // len
-		print("pop rdx\n") // cap
-		print("add rax, rax\n")
-		print("push qword [rbx+rax*8+8]\n")
-		print("push qword [rbx+rax*8]\n")
-		return typeString
-	} else {
-		error("invalid slice type " + typeName(typ))
-		return 0
+// Parse the rest of a possibly-qualified identifier whose first identifier
+// (name) has already been parsed, and return its symbol name.
+func qualifiedName(name string) string {
+	if token != tDot || find(imports, name) < 0 || find(locals, name) >= 0 {
+		return symbolName(name)
+	}
+	next()
+	sel := tokenStr
+	identifier("qualified identifier")
+	if !isUpper(int(sel[0])) {
+		error("name " + sel + " not exported by package " + name)
 	}
+	return name + "." + sel
 }
 
-// Recursive-descent parser
+// Convert the integer on top of the stack to integer type typ.
+func genConvertInt(typ int) {
+	under := underType(typ)
+	if under >= typeInt8 && under <= typeInt32 || under >= typeUint8 && under <= typeUint32 {
+		print("mov rax, [rsp]\n")
+		genTruncate(typ)
+		print("mov [rsp], rax\n")
+	}
+}
 
-func expect(expected int, msg string) {
-	if token != expected {
-		error("expected " + msg + " not " + tokenName(token))
+// Report whether a value of type from can be converted to type to. If
+// either is a type parameter, every type in its type set must be.
+func convertible(from int, to int) bool {
+	param := to
+	if typeKinds[from] == kindParam {
+		param = from
 	}
-	next()
+	if from == to {
+		return true
+	} else if typeKinds[param] == kindParam {
+		if typeElems[param] <= 0 {
+			return false
+		}
+		i := typeElems[param] - 1
+		for unionTypes[i] != 0 {
+			term := unionTypes[i]
+			if term < 0 {
+				term = -term
+			}
+			if param == from && !convertible(term, to) || param == to && !convertible(from, term) {
+				return false
+			}
+			i = i + 1
+		}
+		return true
+	} else if isNumeric(from) && isNumeric(to) || underType(from) == underType(to) {
+		return true
+	} else if isByteSlice(to) && underType(from) == typeString {
+		return true
+	}
+	return underType(to) == typeString && isInteger(from) ||
+		underType(to) == typeString && isByteSlice(from)
 }
 
-func Literal() int {
-	if token == tIntLit {
-		genIntLit(tokenInt)
-		next()
-		return typeInt
-	} else if token == tStrLit {
-		genStrLit(tokenStr)
-		next()
-		return typeString
-	} else {
-		error("expected integer or string literal")
-		return 0
+// Parse a conversion such as "Celsius(f)" (after the type's name), which is
+// allowed between types with the same underlying type, between numeric
+// types, and from strings to []byte or from []byte or integers to strings.
+func Conversion(typ int) int {
+	expect(tLParen, "(")
+	valueType := convertUntyped(Expression(), typ)
+	expect(tRParen, ")")
+	if typeKinds[typ] == kindParam || typeKinds[valueType] == kindParam {
+		if !convertible(valueType, typ) {
+			error("cannot convert " + typeName(valueType) + " to " + typeName(typ))
+		}
+		return typ // see genBinaryParam
+	}
+	if isInteger(valueType) && underType(typ) == typeFloat {
+		if isUnsigned(valueType) {
+			genCall("_uintToFloat")
+		} else {
+			genIntToFloat(0)
+		}
+	} else if underType(valueType) == typeFloat && isInteger(typ) {
+		if isUnsigned(typ) {
+			genCall("_floatToUint")
+		} else {
+			print("cvttsd2si rax, [rsp]\n") // truncate towards zero
+			print("mov [rsp], rax\n")
+		}
+		genConvertInt(typ)
+	} else if isInteger(valueType) && isInteger(typ) {
+		genConvertInt(typ)
+	} else if isByteSlice(typ) && underType(valueType) == typeString {
+		genCall("_stringToBytes")
+	} else if underType(typ) == typeString && isByteSlice(valueType) {
+		genCall("_bytesToString")
+	} else if underType(typ) == typeString && isInteger(valueType) {
+		genCall("_runeToString")
+	} else if valueType == typeNil && canBeNil(typ) {
+		genNil(typ)
+	} else if underType(valueType) != underType(typ) {
+		error("cannot convert " + typeName(valueType) + " to " + typeName(typ))
 	}
+	return typ
 }
 
-func identifier(msg string) {
-	expect(tIdent, msg)
+// Parse a type assertion such as "(int)" (after the "."), of the value of
+// type typ on the stack. In the "v, ok := a.(T)" form the result is a tuple
+// (T, bool), otherwise the assertion panics if a doesn't hold a T.
+func TypeAssertion(typ int) int {
+	if underType(typ) != typeAny {
+		error("invalid type assertion: " + typeName(typ) + " is not an interface")
+	}
+	expect(tLParen, "(")
+	assertType := Type()
+	expect(tRParen, ")")
+	if assertOk == 1 && token == tSemicolon {
+		assertOk = 0
+		return genAssertOk(assertType)
+	}
+	genAssert(assertType)
+	return assertType
 }
 
-func Operand() int {
-	if token == tIntLit || token == tStrLit {
-		return Literal()
-	} else if token == tIdent {
-		name := tokenStr
-		identifier("identifier")
-		return genIdentifier(name)
-	} else {
-		error("expected literal or identifier")
-		return 0
+// Return the function name of method name with receiver type typ.
+func methodName(typ int, name string) string {
+	if isPointer(typ) {
+		typ = elemType(typ)
 	}
+	return typeName(typ) + "." + name
 }
 
-func ExpressionList() int {
-	firstType := Expression()
-	for token == tComma {
-		next()
-		Expression()
+// Parse a method call or type assertion on variable name (after the name),
+// such as ".WriteString(s)" or ".(int)".
+func Selector(name string) int {
+	expect(tDot, ".")
+	typ := varType(name)
+	if token == tLParen {
+		genIdentifier(name)
+		return TypeAssertion(typ)
+	}
+	funcName := methodName(typ, tokenStr)
+	index := find(funcs, funcName)
+	if index < 0 {
+		error(typeName(typ) + " has no method " + tokenStr)
+	}
+	identifier("method name")
+	recvType := funcSigs[funcSigIndexes[index]+2]
+	if recvType == typ {
+		genIdentifier(name)
+	} else if isPointer(recvType) {
+		genAddress(name) // pointer method on addressable variable
+	} else {
+		genIdentifier(name) // value method through pointer
+		print("pop rax\n")
+		genFetchInstrs(recvType, "rax")
 	}
-	return firstType
-}
-
-func Arguments() int {
-	funcName := tokenStr // function name will still be in tokenStr
 	expect(tLParen, "(")
-	arg1Type := typeVoid
-	if token != tRParen {
-		arg1Type = ExpressionList()
-	}
-	expect(tRParen, ")")
+	return callArgs(funcName, 1)
+}
 
-	// Replace "generic" built-in functions with type-specific versions
-	if funcName == "append" {
-		if arg1Type == typeSliceInt {
-			funcName = "_appendInt"
-		} else if arg1Type == typeSliceStr {
-			funcName = "_appendString"
-		} else {
-			error("can't append to " + typeName(arg1Type))
-		}
-	} else if funcName == "len" {
-		if arg1Type == typeString {
-			funcName = "len"
-		} else if arg1Type == typeSliceInt || arg1Type == typeSliceStr {
-			funcName = "_lenSlice"
+func Operand() int {
+	if token == tIntLit || token == tStrLit || token == tFloatLit || token == tCharLit {
+		return Literal()
+	} else if token == tIdent {
+		name := tokenStr
+		identifier("identifier")
+		name = qualifiedName(name)
+		typ := 0
+		if token == tLParen || token == tLBracket && find(generics, name) >= 0 {
+			typ = findType(name)
+			if find(funcs, name) < 0 && typ != 0 {
+				typ = Conversion(typ)
+			} else {
+				typ = Arguments(name)
+			}
+		} else if token == tLBracket && find(genericTypes, name) >= 0 {
+			// Conversion to generic type instance, like List[int](s)
+			typ = Conversion(typeInstance(find(genericTypes, name)))
+		} else if token == tDot {
+			typ = Selector(name)
 		} else {
-			error("can't get length of " + typeName(arg1Type))
+			untypedKind = 0
+			return genIdentifier(name) // sets untypedKind for untyped constants
 		}
+		untypedKind = 0
+		return typ
+	} else if token == tLBracket {
+		// Conversion to slice type, like []byte(s)
+		typ := Conversion(Type())
+		untypedKind = 0
+		return typ
+	} else {
+		error("expected literal or identifier")
+		return 0
 	}
-	return genCall(funcName)
 }
 
 func indexExpr() {
-	typ := Expression()
-	if typ != typeInt {
-		error("slice index must be int")
+	typ := convertUntyped(Expression(), typeInt)
+	if !hasProp(typ, propInteger) {
+		error("invalid slice index of type " + typeName(typ))
 	}
 }
 
 func PrimaryExpr() int {
 	typ := Operand()
-	if token == tLParen {
-		return Arguments()
-	} else if token == tLBracket {
+	if token == tLBracket {
+		srcLine := line
 		next()
 		if token == tColon {
-			if typ != typeSliceInt && typ != typeSliceStr {
+			if !isSlice(typ) {
 				error("slice expression requires slice type")
 			}
 			next()
 			indexExpr()
 			expect(tRBracket, "]")
-			genSliceExpr()
+			genSliceExpr(srcLine)
+			untypedKind = 0
 			return typ
 		}
 		indexExpr()
 		expect(tRBracket, "]")
-		return genSliceFetch(typ)
+		untypedKind = 0
+		typ = genSliceFetch(typ, srcLine)
+		if token == tDot {
+			next()
+			return TypeAssertion(typ)
+		}
 	}
 	return typ
 }
@@ -1186,19 +5645,119 @@ func UnaryExpr() int {
 		op := token
 		next()
 		typ := UnaryExpr()
+		if untypedKind != 0 && op != tNot {
+			if op == tMinus {
+				print("add rsp, 8\n") // replace with negated constant
+				untypedNeg, untypedDigits, untypedExp = decNorm(!untypedNeg, untypedDigits, untypedExp)
+				genUntyped()
+			}
+			return typ
+		}
+		untypedKind = 0
 		genUnary(op, typ)
 		return typ
+	} else if token == tTimes {
+		next()
+		typ := UnaryExpr()
+		if !isPointer(typ) {
+			error("invalid indirect of " + typeName(typ))
+		}
+		print("pop rax\n")
+		genFetchInstrs(elemType(typ), "rax")
+		untypedKind = 0
+		return elemType(typ)
 	}
 	return PrimaryExpr()
 }
 
+// Save the untyped constant (if any) that's the left operand of a binary
+// operator, before parsing the right operand.
+func saveUntyped() {
+	savedKinds = append(savedKinds, untypedKind)
+	if untypedKind == 0 {
+		savedValues = append(savedValues, "")
+		return
+	}
+	savedValues = append(savedValues, decString(untypedNeg, untypedDigits, untypedExp))
+}
+
+// Generate a binary operation where both operands are untyped constants
+// (the left one is saved), computing the result at compile time.
+func foldBinary(op int, kind int, value string) int {
+	neg, digits, exp := decParse(value)
+	print("add rsp, 16\n") // replace operands with result
+	if untypedKind == typeFloat || untypedKind == typeInt32 && kind == typeInt {
+		kind = untypedKind // float beats rune, which beats int
+	}
+	if isComparison(op) {
+		cmp := decCmp(neg, digits, exp, untypedNeg, untypedDigits, untypedExp)
+		if op == tEq && cmp == 0 || op == tNotEq && cmp != 0 ||
+			op == tLess && cmp < 0 || op == tLessEq && cmp <= 0 ||
+			op == tGreater && cmp > 0 || op == tGreaterEq && cmp >= 0 {
+			print("push qword 1\n")
+		} else {
+			print("push qword 0\n")
+		}
+		untypedKind = 0
+		return typeBool
+	}
+	if op == tPlus {
+		neg, digits, exp = decAdd(neg, digits, exp, untypedNeg, untypedDigits, untypedExp)
+	} else if op == tMinus {
+		neg, digits, exp = decAdd(neg, digits, exp, !untypedNeg, untypedDigits, untypedExp)
+	} else if op == tTimes {
+		neg, digits, exp = decMul(neg, digits, exp, untypedNeg, untypedDigits, untypedExp)
+	} else if op == tDivide && kind == typeFloat {
+		neg, digits, exp = decQuo(neg, digits, exp, untypedNeg, untypedDigits, untypedExp)
+	} else if op == tDivide || op == tModulo && kind != typeFloat {
+		neg, digits, exp = decIntDiv(neg, digits, untypedNeg, untypedDigits, op == tModulo)
+	} else if kind == typeFloat {
+		error("operator " + tokenName(op) + " not defined on untyped float")
+	} else if kind == typeInt32 {
+		error("operator " + tokenName(op) + " not defined on untyped rune")
+	} else {
+		error("operator " + tokenName(op) + " not defined on untyped int")
+	}
+	untypedKind = kind
+	untypedNeg = neg
+	untypedDigits = digits
+	untypedExp = exp
+	genUntyped()
+	return kind
+}
+
+// Generate a binary operation on operands of types typ and typRight. If
+// both are untyped constants the result is computed at compile time, and if
+// one is, it's converted to the other operand's type. Parameter srcLine is
+// the operator's source line (for runtime errors).
+func binaryOp(op int, typ int, typRight int, srcLine int) int {
+	n := len(savedKinds) - 1
+	leftKind := savedKinds[n]
+	leftValue := savedValues[n]
+	savedKinds = savedKinds[:n]
+	savedValues = savedValues[:n]
+	if leftKind != 0 && untypedKind != 0 {
+		return foldBinary(op, leftKind, leftValue)
+	}
+	if leftKind != 0 {
+		neg, digits, exp := decParse(leftValue)
+		typ = convertConst(8, leftKind, neg, digits, exp, typRight)
+	} else {
+		typRight = convertUntyped(typRight, typ)
+	}
+	untypedKind = 0
+	return genBinary(op, typ, typRight, srcLine)
+}
+
 func mulExpr() int {
 	typ := UnaryExpr()
 	for token == tTimes || token == tDivide || token == tModulo {
 		op := token
+		srcLine := line
 		next()
+		saveUntyped()
 		typRight := UnaryExpr()
-		typ = genBinary(op, typ, typRight)
+		typ = binaryOp(op, typ, typRight, srcLine)
 	}
 	return typ
 }
@@ -1207,9 +5766,11 @@ func addExpr() int {
 	typ := mulExpr()
 	for token == tPlus || token == tMinus {
 		op := token
+		srcLine := line
 		next()
+		saveUntyped()
 		typRight := mulExpr()
-		typ = genBinary(op, typ, typRight)
+		typ = binaryOp(op, typ, typRight, srcLine)
 	}
 	return typ
 }
@@ -1219,9 +5780,11 @@ func comparisonExpr() int {
 	for token == tEq || token == tNotEq || token == tLess || token == tLessEq ||
 		token == tGreater || token == tGreaterEq {
 		op := token
+		srcLine := line
 		next()
+		saveUntyped()
 		typRight := addExpr()
-		typ = genBinary(op, typ, typRight)
+		typ = binaryOp(op, typ, typRight, srcLine)
 	}
 	return typ
 }
@@ -1230,9 +5793,11 @@ func andExpr() int {
 	typ := comparisonExpr()
 	for token == tAnd {
 		op := token
+		srcLine := line
 		next()
+		saveUntyped()
 		typRight := comparisonExpr()
-		typ = genBinary(op, typ, typRight)
+		typ = binaryOp(op, typ, typRight, srcLine)
 	}
 	return typ
 }
@@ -1241,9 +5806,11 @@ func orExpr() int {
 	typ := andExpr()
 	for token == tOr {
 		op := token
+		srcLine := line
 		next()
+		saveUntyped()
 		typRight := andExpr()
-		typ = genBinary(op, typ, typRight)
+		typ = binaryOp(op, typ, typRight, srcLine)
 	}
 	return typ
 }
@@ -1254,48 +5821,236 @@ func Expression() int {
 
 func PackageClause() {
 	expect(tPackage, "\"package\"")
+	name := tokenStr
 	identifier("package identifier")
+	if name != curPackage {
+		if find(packages, name) >= 0 {
+			error("files of package " + name + " must be contiguous")
+		}
+		packages = append(packages, name)
+		curPackage = name
+	}
+	imports = imports[:0]
+}
+
+func ImportSpec() {
+	path := tokenStr
+	expect(tStrLit, "import path")
+	// Package name is last element of import path
+	name := ""
+	i := 0
+	for i < len(path) {
+		if path[i] == '/' {
+			name = ""
+		} else {
+			name = name + char(int(path[i]))
+		}
+		i = i + 1
+	}
+	if find(packages, name) < 0 || name == curPackage {
+		error("package " + escape(path, "\"") + " not found (imported packages must come first)")
+	}
+	imports = append(imports, name)
+}
+
+func ImportDecl() {
+	expect(tImport, "\"import\"")
+	if token == tLParen {
+		next()
+		for token != tRParen {
+			ImportSpec()
+			expect(tSemicolon, ";")
+		}
+		next()
+	} else {
+		ImportSpec()
+	}
 }
 
 func Type() int {
 	if token == tLBracket {
 		next()
 		expect(tRBracket, "]")
-		name := tokenStr
-		identifier("\"int\" or \"string\"")
-		if name == "int" || name == "bool" {
-			return typeSliceInt
-		} else if name == "string" {
-			return typeSliceStr
-		} else {
-			error("only []int and []string are supported")
-		}
+		return sliceType(Type())
+	} else if token == tTimes {
+		next()
+		return pointerType(Type())
+	} else if token == tFunc {
+		next()
+		return FuncType()
 	}
 	name := tokenStr
-	identifier("\"int\" or \"string\"")
-	if name == "int" || name == "bool" {
-		return typeInt
-	} else if name == "string" {
-		return typeString
+	identifier("type name")
+	name = qualifiedName(name)
+	typ := findType(name)
+	if typ == 0 {
+		d := find(genericTypes, name)
+		if d < 0 {
+			error("undefined type " + name)
+		}
+		typ = typeInstance(d)
+	}
+	return typ
+}
+
+// Parse a type parameter's constraint, and return it as stored in
+// typeElems: 0 for any, -1 for comparable, or 1 plus the index in
+// unionTypes of a union such as "~int | float64".
+func Constraint() int {
+	if token == tIdent && tokenStr == "any" {
+		next()
+		return 0
+	} else if token == tIdent && tokenStr == "comparable" {
+		next()
+		return -1
+	}
+	start := len(unionTypes)
+	for {
+		tilde := token == tTilde
+		if tilde {
+			next()
+		}
+		typ := Type()
+		if tilde && underType(typ) != typ {
+			error("invalid use of ~ (underlying type of " + typeName(typ) + " is " +
+				typeName(underType(typ)) + ")")
+		} else if tilde {
+			typ = -typ
+		}
+		unionTypes = append(unionTypes, typ)
+		if token != tPipe {
+			break
+		}
+		next()
+	}
+	unionTypes = append(unionTypes, 0)
+	return start + 1
+}
+
+// Parse type parameters like "K comparable, V any]" (after the "["), which
+// are then in scope.
+func TypeParameters() {
+	scopeParams = len(typeParams)
+	scopeNumParams = 0
+	scopeArgs = -1
+	for token != tRBracket {
+		first := scopeNumParams
+		for {
+			i := 0
+			for i < scopeNumParams {
+				if typeParams[scopeParams+i] == tokenStr {
+					error("type parameter " + tokenStr + " redeclared")
+				}
+				i = i + 1
+			}
+			typeParams = append(typeParams, tokenStr)
+			typeParamTypes = append(typeParamTypes, newType(tokenStr, 8, kindParam, 0))
+			scopeNumParams = scopeNumParams + 1
+			identifier("type parameter name")
+			if token != tComma {
+				break
+			}
+			next()
+		}
+		c := Constraint()
+		for first < scopeNumParams {
+			typeElems[typeParamTypes[scopeParams+first]] = c
+			first = first + 1
+		}
+		if token != tRBracket {
+			expect(tComma, ",")
+		}
+	}
+	next()
+	if scopeNumParams == 0 {
+		error("empty type parameter list")
+	}
+}
+
+// Parse the rest of generic type name's declaration (after the "["), such
+// as "T any] []T". Its instances are named types (see instantiateType).
+func GenericTypeDecl(name string) {
+	TypeParameters()
+	genericTypes = append(genericTypes, name)
+	genericTypeParams = append(genericTypeParams, scopeParams)
+	genericTypeNumParams = append(genericTypeNumParams, scopeNumParams)
+	genericTypeUnders = append(genericTypeUnders, underType(Type()))
+	scopeNumParams = 0
+}
+
+// Parse a type declaration such as "type Celsius int", which defines a named
+// type with the given underlying type, or a generic one like
+// "type List[T any] []T".
+func TypeDecl() {
+	expect(tType, "\"type\"")
+	name := declName(tokenStr)
+	identifier("type name")
+	if findType(name) != 0 || find(genericTypes, name) >= 0 {
+		error("type " + name + " redeclared")
+	}
+	typ := 0
+	if token == tLBracket {
+		next()
+		if token != tRBracket {
+			GenericTypeDecl(name)
+			return
+		}
+		next()
+		typ = sliceType(Type()) // not generic, like "type Ints []int"
 	} else {
-		error("only int and string are supported")
+		typ = Type()
 	}
-	return typeVoid
+	under := underType(typ)
+	newType(name, typeSize(under), kindNamed, under)
 }
 
 func defineLocal(typ int, name string) {
 	locals = append(locals, name)
 	localTypes = append(localTypes, typ)
+	block := 0 // parameters are outside the function's block
+	if len(openBlocks) > 0 {
+		block = openBlocks[len(openBlocks)-1]
+	}
+	localBlocks = append(localBlocks, block)
+}
+
+// Parse the rest of a local variable declaration of name: a type, a value,
+// or both. Without a value the variable is set to its type's zero value.
+func localVarSpec(name string) {
+	typ := 0
+	if token != tAssign {
+		typ = Type()
+	}
+	if token == tAssign {
+		next()
+		valueType := Expression()
+		if typ == 0 {
+			typ = convertUntyped(valueType, valueType)
+			if typ == typeNil {
+				error("use of untyped nil in variable declaration")
+			} else if typeKinds[typ] == kindTuple {
+				error("assignment mismatch: 1 variable but " + itoa(tupleLen(typ)) + " values")
+			}
+		} else {
+			genAssignable(valueType, typ, "variable declaration")
+		}
+	} else {
+		genZero(typ)
+	}
+	defineLocal(typ, name)
+	genAssign(name)
 }
 
 func VarSpec() {
 	// We only support a single identifier, not a list
 	varName := tokenStr
 	identifier("variable identifier")
-	typ := Type()
 	if curFunc != "" {
-		error("\"var\" not supported inside functions")
+		localVarSpec(varName)
+		return
 	}
+	varName = declName(varName)
+	typ := Type()
 	globals = append(globals, varName)
 	globalTypes = append(globalTypes, typ)
 	if token == tAssign {
@@ -1305,7 +6060,11 @@ func VarSpec() {
 
 func VarDecl() {
 	expect(tVar, "\"var\"")
-	expect(tLParen, "(")
+	if token != tLParen {
+		VarSpec()
+		return
+	}
+	next()
 	for token != tRParen {
 		VarSpec()
 		expect(tSemicolon, ";")
@@ -1313,19 +6072,53 @@ func VarDecl() {
 	expect(tRParen, ")")
 }
 
+// Generate typed integer constant name with the given value (see decString).
+func genIntConst(name string, value string, typ int) {
+	neg, digits, exp := decParse(value)
+	genConst(name, constBits(neg, digits, exp, typ))
+}
+
 func ConstSpec() {
-	// We only support typed integer constants
-	name := tokenStr
+	// We only support a (possibly negated) integer, floating-point or
+	// character literal, with an optional type
+	name := declName(tokenStr)
 	consts = append(consts, name)
-	identifier("variable identifier")
-	typ := Type()
-	if typ != typeInt {
-		error("constants must be typed int")
+	identifier("constant identifier")
+	typ := 0
+	if token != tAssign {
+		typ = Type()
+		if !isNumeric(typ) {
+			error("constants must be numeric")
+		}
 	}
 	expect(tAssign, "=")
-	value := tokenInt
-	expect(tIntLit, "integer literal")
-	genConst(name, value)
+	neg := false
+	if token == tMinus {
+		neg = true
+		next()
+	}
+	kind := typeInt
+	exp := 0
+	if token == tFloatLit {
+		kind = typeFloat
+		exp = tokenInt
+	} else if token == tCharLit {
+		kind = typeInt32
+	} else if token != tIntLit {
+		error("expected integer, floating-point or character literal")
+	}
+	neg, digits, exp := decNorm(neg, tokenStr, exp)
+	next()
+	constValues = append(constValues, decString(neg, digits, exp))
+	constUntyped = append(constUntyped, typ == 0)
+	if typ == 0 {
+		constTypes = append(constTypes, kind)
+		return
+	}
+	constTypes = append(constTypes, typ)
+	if isInteger(typ) {
+		genIntConst(name, constValues[len(constValues)-1], typ)
+	}
 }
 
 func ConstDecl() {
@@ -1341,7 +6134,16 @@ func ConstDecl() {
 func ParameterDecl() {
 	paramName := tokenStr
 	identifier("parameter name")
-	typ := Type()
+	typ := 0
+	if token == tEllipsis {
+		// Variadic parameter is a slice of the given type
+		next()
+		elem := Type()
+		typ = sliceType(elem)
+		funcVariadics[len(funcVariadics)-1] = elem
+	} else {
+		typ = Type()
+	}
 	defineLocal(typ, paramName)
 	funcSigs = append(funcSigs, typ)
 	resultIndex := funcSigIndexes[len(funcSigIndexes)-1]
@@ -1351,6 +6153,9 @@ func ParameterDecl() {
 func ParameterList() {
 	ParameterDecl()
 	for token == tComma {
+		if funcVariadics[len(funcVariadics)-1] != 0 {
+			error("can only use ... with final parameter")
+		}
 		next()
 		ParameterDecl()
 	}
@@ -1364,46 +6169,252 @@ func Parameters() {
 	expect(tRParen, ")")
 }
 
-func Signature() {
+// Parse a function's result type, which may be a list of types such as
+// "(int, error)".
+func Result() int {
+	if token != tLParen {
+		return Type()
+	}
+	next()
+	start := len(tupleTypes)
+	tupleTypes = append(tupleTypes, 0)
+	for token != tRParen {
+		tupleTypes = append(tupleTypes, Type())
+		tupleTypes[start] = tupleTypes[start] + 1
+		if token != tRParen {
+			expect(tComma, ",")
+		}
+	}
+	next()
+	if tupleTypes[start] == 1 {
+		typ := tupleTypes[start+1]
+		tupleTypes = tupleTypes[:start]
+		return typ
+	}
+	typ := tupleType(start)
+	if typeSize(typ) > 48 {
+		error("results too big (they're returned in 6 registers)")
+	}
+	return typ
+}
+
+// Parse a function type such as "func(int, string) bool" (after the "func").
+func FuncType() int {
+	start := len(sigStack)
+	sigStack = append(sigStack, typeVoid, 0)
+	expect(tLParen, "(")
+	typ := 0
+	for token != tRParen {
+		typ = Type()
+		sigStack = append(sigStack, typ)
+		sigStack[start+1] = sigStack[start+1] + 1
+		if token != tRParen {
+			expect(tComma, ",")
+		}
+	}
+	next()
+	if token == tIdent || token == tLBracket || token == tTimes || token == tFunc ||
+		token == tLParen {
+		typ = Result()
+		sigStack[start] = typ
+	}
+	return funcType(start)
+}
+
+// Parse a function's signature, with the receiver (or 0) as its first
+// parameter.
+func Signature(recvType int) {
 	funcSigs = append(funcSigs, typeVoid) // space for result type
 	funcSigs = append(funcSigs, 0)        // space for numArgs
+	if recvType != 0 {
+		funcSigs = append(funcSigs, recvType)
+		funcSigs[len(funcSigs)-2] = 1
+	}
 	Parameters()
 	if token != tLBrace {
-		typ := Type()
+		typ := Result()
 		resultIndex := funcSigIndexes[len(funcSigIndexes)-1]
 		funcSigs[resultIndex] = typ // set result type
 	}
 }
 
+// Return the type of variable i in assignNames if it's already defined, for
+// converting untyped constants, or 0 if it's being defined.
+func assignType(i int, define bool) int {
+	if i >= len(assignNames) {
+		return 0
+	}
+	name := assignNames[i]
+	if name == "_" || define && find(locals, name) < 0 {
+		return 0
+	}
+	return varType(symbolName(name))
+}
+
+// Parse the rest of an assignment of a list of values, or of a call with
+// multiple results, to a list of variables (the first of which, name, has
+// been parsed), such as "a, b = b, a" or "n, err := strconv.Atoi(s)".
+func ListAssignStmt(name string) {
+	assignNames = append(assignNames[:0], name)
+	for token == tComma {
+		next()
+		assignNames = append(assignNames, tokenStr)
+		identifier("variable name")
+	}
+	define := token == tDeclAssign
+	if define {
+		next()
+	} else {
+		expect(tAssign, "= or :=")
+	}
+	assertOk = 1
+	typ := convertUntyped(Expression(), assignType(0, define))
+	assertOk = 0
+	assignTypes = assignTypes[:0]
+	i := 0
+	for i < tupleLen(typ) {
+		assignTypes = append(assignTypes, tupleElem(typ, i))
+		i = i + 1
+	}
+	for token == tComma {
+		next()
+		if typeKinds[typ] == kindTuple {
+			error("multiple-value " + typeName(typ) + " in single-value context")
+		}
+		typ = convertUntyped(Expression(), assignType(len(assignTypes), define))
+		assignTypes = append(assignTypes, typ)
+	}
+	if len(assignTypes) != len(assignNames) {
+		values := " values"
+		if len(assignTypes) == 1 {
+			values = " value"
+		}
+		error("assignment mismatch: " + itoa(len(assignNames)) + " variables but " +
+			itoa(len(assignTypes)) + values)
+	}
+
+	// Values are on the stack, so assign them last to first
+	newVars := 0
+	i = len(assignNames) - 1
+	for i >= 0 {
+		name = assignNames[i]
+		typ = assignTypes[i]
+		if name == "_" {
+			genDiscard(typ)
+		} else if define && find(locals, name) < 0 {
+			if typ == typeNil {
+				error("use of untyped nil in assignment")
+			}
+			defineLocal(typ, name)
+			genAssign(name)
+			newVars = newVars + 1
+		} else {
+			name = symbolName(name)
+			genAssignable(typ, varType(name), "assignment")
+			genAssign(name)
+		}
+		i = i - 1
+	}
+	if define && newVars == 0 {
+		error("no new variables on left side of :=")
+	}
+}
+
+// Parse an assignment through a pointer, such as "*p = x".
+func PointerAssignStmt() {
+	expect(tTimes, "*")
+	name := symbolName(tokenStr)
+	identifier("pointer variable")
+	typ := varType(name)
+	if !isPointer(typ) {
+		error("invalid indirect of " + name)
+	}
+	expect(tAssign, "=")
+	genAssignable(Expression(), elemType(typ), "assignment")
+	genIdentifier(name)
+	print("pop rax\n")
+	genAssignInstrs(elemType(typ), "rax")
+}
+
+// Parse the rest of an "x++" or "x--" statement, where x is identName.
+func IncDecStmt(identName string) {
+	op := tPlus
+	if token == tDec {
+		op = tMinus
+	}
+	srcLine := line
+	next()
+	typ := genIdentifier(identName)
+	if !hasProp(typ, propNumeric) {
+		error("invalid operation: " + identName + tokenName(op) + tokenName(op) +
+			" (non-numeric type " + typeName(typ) + ")")
+	}
+	saveUntyped()
+	genIntLit(1)
+	untypedKind = typeInt
+	untypedNeg = false
+	untypedDigits = "1"
+	untypedExp = 0
+	binaryOp(op, typ, typeInt, srcLine)
+	genAssign(identName)
+}
+
 func SimpleStmt() {
+	if token == tTimes {
+		PointerAssignStmt()
+		return
+	}
 	// Funky parsing here to handle assignments
 	identName := tokenStr
 	expect(tIdent, "assignment or call statement")
-	if token == tAssign {
+	if token == tComma {
+		ListAssignStmt(identName)
+		return
+	}
+	if token == tDeclAssign {
 		next()
-		lhsType := varType(identName)
-		rhsType := Expression()
-		if lhsType != rhsType {
-			error("can't assign " + typeName(rhsType) + " to " +
-				typeName(lhsType))
+		typ := Expression()
+		typ = convertUntyped(typ, typ) // check that it fits its default type
+		if typ == typeNil {
+			error("use of untyped nil in assignment")
+		} else if typeKinds[typ] == kindTuple {
+			error("assignment mismatch: 1 variable but " + itoa(tupleLen(typ)) + " values")
 		}
+		defineLocal(typ, identName)
 		genAssign(identName)
-	} else if token == tDeclAssign {
+		return
+	}
+	if identName == "_" {
+		expect(tAssign, "=")
+		genDiscard(Expression())
+		return
+	}
+	identName = qualifiedName(identName)
+	if token == tInc || token == tDec {
+		IncDecStmt(identName)
+	} else if token == tAssign {
 		next()
-		typ := Expression()
-		defineLocal(typ, identName)
+		lhsType := varType(identName)
+		rhsType := Expression()
+		genAssignable(rhsType, lhsType, "assignment")
 		genAssign(identName)
-	} else if token == tLParen {
-		genIdentifier(identName)
-		typ := Arguments()
+	} else if token == tLParen || token == tLBracket && find(generics, identName) >= 0 {
+		typ := Arguments(identName)
 		genDiscard(typ) // discard return value
+	} else if token == tDot {
+		genDiscard(Selector(identName)) // method call
 	} else if token == tLBracket {
+		typ := varType(identName)
+		if !isSlice(typ) {
+			error("cannot assign to element of " + typeName(typ))
+		}
+		srcLine := line
 		next()
 		indexExpr()
 		expect(tRBracket, "]")
 		expect(tAssign, "=")
-		Expression()
-		genSliceAssign(identName)
+		genAssignable(Expression(), elemType(typ), "assignment")
+		genSliceAssign(identName, srcLine)
 	} else {
 		error("expected assignment or call not " + tokenName(token))
 	}
@@ -1411,22 +6422,50 @@ func SimpleStmt() {
 
 func ReturnStmt() {
 	expect(tReturn, "\"return\"")
-	if token != tSemicolon {
-		typ := Expression()
-		genReturn(typ)
-	} else {
+	index := find(funcs, curFunc)
+	sigIndex := funcSigIndexes[index]
+	resultType := funcSigs[sigIndex]
+	if token == tSemicolon {
+		if resultType != typeVoid {
+			error("not enough return values")
+		}
 		genReturn(typeVoid)
+		return
 	}
-}
-
-func newLabel() string {
-	labelNum = labelNum + 1
-	return "label" + itoa(labelNum)
+	if resultType == typeVoid {
+		error("too many return values")
+	}
+	typ := Expression()
+	n := tupleLen(resultType)
+	if typeKinds[typ] == kindTuple && n > 1 {
+		// Return results of a call with multiple results ("return f()")
+		if typ != resultType {
+			error("cannot use " + typeName(typ) + " as " + typeName(resultType) +
+				" value in return statement")
+		}
+	} else {
+		genAssignable(typ, tupleElem(resultType, 0), "return statement")
+		i := 1
+		for token == tComma {
+			next()
+			if i >= n {
+				error("too many return values")
+			}
+			genAssignable(Expression(), tupleElem(resultType, i), "return statement")
+			i = i + 1
+		}
+		if i < n {
+			error("not enough return values")
+		}
+	}
+	genReturn(resultType)
 }
 
 func IfStmt() {
 	expect(tIf, "\"if\"")
-	Expression()
+	if underType(Expression()) != typeBool {
+		error("non-boolean condition in if statement")
+	}
 	ifLabel := newLabel()
 	genJumpIfZero(ifLabel) // jump to else or end of if block
 	Block()
@@ -1446,14 +6485,249 @@ func IfStmt() {
 	}
 }
 
+// Record the labels that "continue" and "break" jump to in a loop (and
+// the loop's user label name, or "").
+func pushLoop(name string, contLabel string, breakLabel string) {
+	contLabels = append(contLabels, contLabel)
+	breakLabels = append(breakLabels, breakLabel)
+	loopNames = append(loopNames, name)
+	loopDepths = append(loopDepths, rangeDepth)
+}
+
+func popLoop() {
+	contLabels = contLabels[:len(contLabels)-1]
+	breakLabels = breakLabels[:len(breakLabels)-1]
+	loopNames = loopNames[:len(loopNames)-1]
+	loopDepths = loopDepths[:len(loopDepths)-1]
+}
+
+// Open a new block (blocks are tracked to check goto statements).
+func openBlock() {
+	blockCount = blockCount + 1
+	openBlocks = append(openBlocks, blockCount)
+}
+
+func closeBlock() {
+	openBlocks = openBlocks[:len(openBlocks)-1]
+}
+
+// Return the assembly label for user label name. The "L." prefix and the
+// function name mean it can't collide with generated or function labels.
+func labelSym(name string) string {
+	return "L." + curFunc + "." + name
+}
+
+func useLabel(name string) {
+	labelUsed[find(labelNames, name)] = 1
+}
+
+func BranchStmt() {
+	op := token
+	next()
+	if op == tFallthrough {
+		error("fallthrough statement out of place")
+	}
+	if len(breakLabels) == 0 {
+		error(tokenName(op) + " is not in a loop")
+	}
+	i := len(breakLabels) - 1
+	if token == tIdent {
+		// Labeled break or continue: find enclosing loop with that label
+		for i >= 0 && loopNames[i] != tokenStr {
+			i = i - 1
+		}
+		if i < 0 {
+			error("invalid " + tokenName(op) + " label " + tokenStr)
+		}
+		useLabel(tokenStr)
+		next()
+	}
+	if rangeDepth > loopDepths[i] {
+		// Pop the state of the "range" loops being exited
+		print("add rsp, " + itoa(24*rangeDepth-24*loopDepths[i]) + "\n")
+	}
+	if op == tBreak {
+		genJump(breakLabels[i])
+	} else {
+		genJump(contLabels[i])
+	}
+}
+
+func GotoStmt() {
+	expect(tGoto, "\"goto\"")
+	name := tokenStr
+	identifier("label")
+	i := find(labelNames, name)
+	if i >= 0 {
+		// Backward goto: label must be in an enclosing block
+		if findInt(openBlocks, labelBlocks[i]) < 0 {
+			error("goto " + name + " jumps into block")
+		}
+		labelUsed[i] = 1
+	} else {
+		// Forward goto: checked when the label is defined
+		gotoNames = append(gotoNames, name)
+		gotoBlocks = append(gotoBlocks, blockCount)
+		gotoLocals = append(gotoLocals, len(locals))
+	}
+	genJump(labelSym(name))
+}
+
+// Check the forward gotos to label name, which is being defined in the
+// current block.
+func resolveGotos(name string) {
+	block := openBlocks[len(openBlocks)-1]
+	i := 0
+	for i < len(gotoNames) {
+		if gotoNames[i] == name {
+			if block > gotoBlocks[i] {
+				// Block was opened after the goto, so doesn't enclose it
+				error("goto " + name + " jumps into block")
+			}
+			j := gotoLocals[i]
+			for j < len(locals) {
+				if findInt(openBlocks, localBlocks[j]) >= 0 {
+					error("goto " + name + " jumps over declaration of " + locals[j])
+				}
+				j = j + 1
+			}
+			gotoNames[i] = ""
+			useLabel(name)
+		}
+		i = i + 1
+	}
+}
+
+// Parse a labeled statement, after the label name.
+func LabeledStmt(name string) {
+	expect(tColon, ":")
+	if find(labelNames, name) >= 0 {
+		error("label " + name + " already defined")
+	}
+	labelNames = append(labelNames, name)
+	labelBlocks = append(labelBlocks, openBlocks[len(openBlocks)-1])
+	labelUsed = append(labelUsed, 0)
+	resolveGotos(name)
+	genLabel(labelSym(name))
+	// A goto may come from inside "range" loops, so reset the stack depth
+	print("lea rsp, [rbp-" + itoa(localSpace+24*rangeDepth) + "]\n")
+	if token == tRBrace || token == tSemicolon {
+		return // empty statement
+	}
+	if token == tFor {
+		loopName = name
+	}
+	Statement()
+}
+
+// Check the labels at the end of a function and reset them for the next.
+func endLabels() {
+	i := 0
+	for i < len(gotoNames) {
+		if gotoNames[i] != "" {
+			error("label " + gotoNames[i] + " not defined")
+		}
+		i = i + 1
+	}
+	i = 0
+	for i < len(labelNames) {
+		if labelUsed[i] == 0 {
+			error("label " + labelNames[i] + " defined and not used")
+		}
+		i = i + 1
+	}
+	labelNames = labelNames[:0]
+	labelBlocks = labelBlocks[:0]
+	labelUsed = labelUsed[:0]
+	gotoNames = gotoNames[:0]
+	gotoBlocks = gotoBlocks[:0]
+	gotoLocals = gotoLocals[:0]
+	blockCount = 0
+}
+
+// Define local for a "range" loop variable (unless it's "_" or already
+// defined), and assign it the value on top of the stack.
+func rangeVar(typ int, name string) {
+	if name == "_" {
+		genDiscard(typ)
+		return
+	}
+	if find(locals, name) < 0 {
+		defineLocal(typ, name)
+	} else if varType(name) != typ {
+		error("can't assign " + typeName(typ) + " to " + typeName(varType(name)))
+	}
+	genAssign(name)
+}
+
+// Parse the rest of a "for i, r := range s" statement over a string, after
+// the first identifier (keyName, or "" for "for range s"). The loop's user
+// label is name, or "".
+func RangeStmt(name string, keyName string) {
+	valueName := "_"
+	if keyName != "" {
+		if token == tComma {
+			next()
+			valueName = tokenStr
+			identifier("identifier")
+		}
+		expect(tDeclAssign, ":=")
+	} else {
+		keyName = "_"
+	}
+	expect(tRange, "\"range\"")
+	if Expression() != typeString {
+		error("range is only supported over strings")
+	}
+	genRangeStart()
+	rangeDepth = rangeDepth + 1
+	loopLabel := newLabel()
+	genLabel(loopLabel) // top of loop
+	doneLabel := newLabel()
+	genRangeNext(doneLabel)
+	openBlock() // loop variables are in an implicit block
+	rangeVar(typeInt, keyName)
+	rangeVar(typeInt32, valueName)
+	pushLoop(name, loopLabel, doneLabel)
+	Block()
+	popLoop()
+	closeBlock()
+	genJump(loopLabel) // go back to top of loop
+	genLabel(doneLabel)
+	genRangeEnd()
+	rangeDepth = rangeDepth - 1
+}
+
 func ForStmt() {
+	name := loopName
+	loopName = ""
 	expect(tFor, "\"for\"")
+	if token == tRange {
+		RangeStmt(name, "")
+		return
+	}
+	if token == tIdent {
+		// Look ahead to see if it's a "range" loop
+		keyName := tokenStr
+		next()
+		if token == tComma || token == tDeclAssign {
+			RangeStmt(name, keyName)
+			return
+		}
+		unreadIdent(keyName)
+	}
 	loopLabel := newLabel()
 	genLabel(loopLabel) // top of loop
-	Expression()
 	doneLabel := newLabel()
-	genJumpIfZero(doneLabel) // jump to after loop if done
+	if token != tLBrace {
+		if underType(Expression()) != typeBool {
+			error("non-boolean condition in for statement")
+		}
+		genJumpIfZero(doneLabel) // jump to after loop if done
+	}
+	pushLoop(name, loopLabel, doneLabel)
 	Block()
+	popLoop()
 	genJump(loopLabel) // go back to top of loop
 	genLabel(doneLabel)
 }
@@ -1465,7 +6739,25 @@ func Statement() {
 		ForStmt()
 	} else if token == tReturn {
 		ReturnStmt()
+	} else if token == tBreak || token == tContinue || token == tFallthrough {
+		BranchStmt()
+	} else if token == tGoto {
+		GotoStmt()
+	} else if token == tVar {
+		VarDecl()
+	} else if token == tLBrace {
+		Block()
 	} else {
+		if token == tIdent {
+			// Look ahead to see if it's a labeled statement
+			name := tokenStr
+			next()
+			if token == tColon {
+				LabeledStmt(name)
+				return
+			}
+			unreadIdent(name)
+		}
 		SimpleStmt()
 	}
 }
@@ -1473,13 +6765,17 @@ func Statement() {
 func StatementList() {
 	for token != tRBrace {
 		Statement()
-		expect(tSemicolon, ";")
+		if token != tRBrace {
+			expect(tSemicolon, ";") // may be omitted before "}"
+		}
 	}
 }
 
 func Block() {
 	expect(tLBrace, "{")
+	openBlock()
 	StatementList()
+	closeBlock()
 	expect(tRBrace, "}")
 }
 
@@ -1487,21 +6783,116 @@ func FunctionBody() {
 	Block()
 }
 
-func FunctionDecl() {
-	expect(tFunc, "\"func\"")
-	curFunc = tokenStr
-	genFuncStart(tokenStr)
-	funcs = append(funcs, tokenStr)
+// Parse a method's receiver, such as "(b *Builder)", define it as the first
+// parameter, and return its type.
+func Receiver() int {
+	expect(tLParen, "(")
+	name := tokenStr
+	identifier("receiver name")
+	typ := Type()
+	expect(tRParen, ")")
+	base := typ
+	if isPointer(typ) {
+		base = elemType(typ)
+	}
+	if typeKinds[base] != kindNamed {
+		error("invalid receiver type " + typeName(typ))
+	}
+	defineLocal(typ, name)
+	return typ
+}
+
+// Compile function or method name (after its name), whose receiver (if any)
+// has been defined as a local of type recvType.
+func compileFunc(name string, recvType int) {
+	curFunc = name
+	genFuncStart(curFunc)
+	funcs = append(funcs, curFunc)
 	funcSigIndexes = append(funcSigIndexes, len(funcSigs))
-	identifier("function name")
-	Signature()
+	funcVariadics = append(funcVariadics, 0)
+	Signature(recvType)
 	FunctionBody()
 	genFuncEnd()
+	endLabels()
 	locals = locals[:0]
 	localTypes = localTypes[:0]
+	localBlocks = localBlocks[:0]
 	curFunc = ""
 }
 
+// Parse the rest of generic function name's declaration (after the "[").
+// Its tokens after the type parameters are recorded, and its body is
+// compiled to check it, with the type parameters as types that only allow
+// the operations their constraints do. That code is never run: calls are to
+// instances compiled later by genInstance.
+func GenericDecl(name string) {
+	generics = append(generics, name)
+	genericPackages = append(genericPackages, curPackage)
+	genericImportStarts = append(genericImportStarts, len(genericImports))
+	genericImports = append(genericImports, imports...)
+	genericImports = append(genericImports, "")
+	TypeParameters()
+	genericParams = append(genericParams, scopeParams)
+	genericNumParams = append(genericNumParams, scopeNumParams)
+	genericStarts = append(genericStarts, len(recTokens))
+	recordToken()
+	recording = true
+	compileFunc(name, 0)
+	recording = false
+	genericEnds = append(genericEnds, len(recTokens))
+	scopeNumParams = 0
+}
+
+// Compile instance k of a generic function by replaying its tokens, with
+// its type parameters bound to the instance's type arguments.
+func genInstance(k int) {
+	g := instanceGenerics[k]
+	curPackage = genericPackages[g]
+	imports = imports[:0]
+	i := genericImportStarts[g]
+	for genericImports[i] != "" {
+		imports = append(imports, genericImports[i])
+		i = i + 1
+	}
+	scopeParams = genericParams[g]
+	scopeNumParams = genericNumParams[g]
+	scopeArgs = instanceArgs[k]
+	replayPos = genericStarts[g]
+	replayEnd = genericEnds[g]
+	next()
+	compileFunc(instances[k], 0)
+	replayEnd = 0
+	scopeNumParams = 0
+}
+
+func FunctionDecl() {
+	expect(tFunc, "\"func\"")
+	recvType := 0
+	if token == tLParen {
+		recvType = Receiver()
+	}
+	name := tokenStr
+	identifier("function name")
+	if recvType != 0 {
+		compileFunc(methodName(recvType, name), recvType)
+	} else if token == tLBracket {
+		next()
+		GenericDecl(declName(name))
+	} else if name == "init" {
+		// A package can have several init functions, and they can't be
+		// referred to, so each gets a unique name
+		name = declName("init." + itoa(len(initFuncs)))
+		initFuncs = append(initFuncs, name)
+		compileFunc(name, 0)
+		sigIndex := funcSigIndexes[len(funcs)-1]
+		if funcSigs[sigIndex] != typeVoid || funcSigs[sigIndex+1] != 0 {
+			error("func init must have no arguments and no return values")
+		}
+	} else {
+		compileFunc(declName(name), 0)
+	}
+}
+
 func TopLevelDecl() {
 	if token == tVar {
 		VarDecl()
@@ -1510,8 +6901,10 @@ func TopLevelDecl() {
 		ConstDecl()
 	} else if token == tFunc {
 		FunctionDecl()
+	} else if token == tType {
+		TypeDecl()
 	} else {
-		error("expected \"var\", \"const\", or \"func\"")
+		error("expected \"var\", \"const\", \"type\", or \"func\"")
 	}
 }
 
@@ -1519,39 +6912,68 @@ func SourceFile() {
 	PackageClause()
 	expect(tSemicolon, ";")
 
-	for token == tVar || token == tFunc || token == tConst {
+	for token == tImport {
+		ImportDecl()
+		expect(tSemicolon, ";")
+	}
+
+	for token == tVar || token == tFunc || token == tConst || token == tType {
 		TopLevelDecl()
 		expect(tSemicolon, ";")
 	}
+}
 
+// Parse one or more source files concatenated together. Files of the same
+// package must be contiguous, and imported packages must come first.
+func SourceFiles() {
+	SourceFile()
+	for token == tPackage {
+		SourceFile()
+	}
 	expect(tEOF, "end of file")
+
+	// Compile instances of generic functions (which may add more)
+	i := 0
+	for i < len(instances) {
+		genInstance(i)
+		i = i + 1
+	}
 }
 
-func addFunc(name string, resultType int, numArgs int, arg1Type int, arg2Type int) {
+func addFunc(name string, resultType int, argTypes ...int) {
 	funcs = append(funcs, name)
 	funcSigIndexes = append(funcSigIndexes, len(funcSigs))
-	funcSigs = append(funcSigs, resultType)
-	funcSigs = append(funcSigs, numArgs)
-	if numArgs > 0 {
-		funcSigs = append(funcSigs, arg1Type)
-	}
-	if numArgs > 1 {
-		funcSigs = append(funcSigs, arg2Type)
-	}
+	funcVariadics = append(funcVariadics, 0)
+	funcSigs = append(funcSigs, resultType, len(argTypes))
+	funcSigs = append(funcSigs, argTypes...)
 }
 
-func addToken(name string) {
-	tokens = append(tokens, name)
+// Add built-in generic function name with one type parameter (any), and
+// return the type parameter. Its instances are runtime routines (see
+// builtinCall).
+func addGeneric(name string) int {
+	generics = append(generics, name)
+	genericStarts = append(genericStarts, -1)
+	genericEnds = append(genericEnds, -1)
+	genericPackages = append(genericPackages, "")
+	genericImportStarts = append(genericImportStarts, len(genericImports))
+	genericImports = append(genericImports, "")
+	genericParams = append(genericParams, len(typeParams))
+	genericNumParams = append(genericNumParams, 1)
+	typeParams = append(typeParams, "T")
+	typ := newType("T", 8, kindParam, 0)
+	typeParamTypes = append(typeParamTypes, typ)
+	return typ
 }
 
-func addType(name string, size int) {
-	types = append(types, name)
-	typeSizes = append(typeSizes, size)
+func addToken(name string) {
+	tokens = append(tokens, name)
 }
 
 // Test constructs not used in compiler itself.
 var (
 	testSlice []string
+	testInts  []int
 )
 
 func testAppend(sl []string, s string) []string {
@@ -1572,23 +6994,333 @@ func testUnused() {
 	}
 }
 
+func testJoin(prefix string, nums ...int) string {
+	s := prefix
+	i := 0
+	for i < len(nums) {
+		s = s + itoa(nums[i])
+		i = i + 1
+	}
+	return s
+}
+
+func testVariadic() {
+	if testJoin("x") != "x" || testJoin("x", 1, 2, 3) != "x123" {
+		error("fail: variadic call")
+	}
+	nums := append(testInts, 1, 2)
+	nums = append(nums, nums...)
+	if testJoin("", nums...) != "1212" {
+		error("fail: variadic append or spread call")
+	}
+	sl := append(testSlice, "a", "b")
+	sl = append(sl, sl...)
+	if len(sl) != 4 || sl[3] != "b" {
+		error("fail: variadic append of strings")
+	}
+	nums = append(testInts, 1, 2, 3, 4)
+	nums = nums[:1]
+	nums = append(nums, nums[:3]...) // overlaps, as cap(nums) >= 4
+	if len(nums) != 4 || nums[1] != 1 || nums[2] != 2 || nums[3] != 3 {
+		error("fail: variadic append of overlapping slice")
+	}
+}
+
+type testNames []string
+
+func (n *testNames) add(s string) int {
+	*n = append(*n, s)
+	return len(*n)
+}
+
+func (n testNames) last() string {
+	return n[len(n)-1]
+}
+
+func testDivMod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+type testList[T any] []T
+
+func testSum[T int | float64](nums ...T) T {
+	total := T(0)
+	i := 0
+	for i < len(nums) {
+		total = total + nums[i]
+		i++
+	}
+	return total
+}
+
+func testReverse[T any](s []T) []T {
+	i := 0
+	for i < len(s)/2 {
+		tmp := s[i]
+		s[i] = s[len(s)-1-i]
+		s[len(s)-1-i] = tmp
+		i++
+	}
+	return s
+}
+
+func testPush[T any](l testList[T], x T) testList[T] {
+	return append(l, x)
+}
+
+func testMap[T, U any](s []T, f func(T) U) []U {
+	var r []U
+	i := 0
+	for i < len(s) {
+		r = append(r, f(s[i]))
+		i++
+	}
+	return r
+}
+
+func testGenerics() {
+	nums := testReverse(append(testInts, 1, 2, 3))
+	if nums[0] != 3 || nums[2] != 1 {
+		error("fail: generic call with []T")
+	}
+	if testSum(1, 2, 3) != 6 || testSum(nums...) != 6 || testSum[float64](1.5, 2) != 3.5 ||
+		testSum[int]() != 0 {
+		error("fail: generic call with ...T")
+	}
+	l := testPush(testPush[string](testSlice, "a"), "b")
+	if len(l) != 2 || l[1] != "b" {
+		error("fail: generic type")
+	}
+	l = testMap(nums, itoa)
+	if len(l) != 3 || l[0] != "3" {
+		error("fail: generic call with func(T) U")
+	}
+}
+
+func testSized() {
+	b := byte(255)
+	b = b + 1
+	u := uint64(0)
+	i := int8(b + 128)
+	if b != 0 || u-1 < u || i != -128 || -i != i || uint32(i)/2 != 2147483584 {
+		error("fail: sized integer arithmetic")
+	}
+	u = 18446744073709551615
+	n := -40000
+	if u/10 != 1844674407370955161 || u%10 != 5 || float64(u) != 18446744073709551615.0 ||
+		uint64(float64(u/2)) != 9223372036854775808 || int16(n) != 25536 {
+		error("fail: unsigned 64-bit integers")
+	}
+}
+
+func testBytes() {
+	b := []byte("ab")
+	b = append(b, 'c')
+	b = append(b, "de"...)
+	b[0] = 'A'
+	if string(b) != "Abcde" || b[1] != 'b' || len(string(int32(8364))) != 3 || len(b[:2]) != 2 {
+		error("fail: []byte and string conversions")
+	}
+}
+
+func testRunes() {
+	n := 0
+	for i, r := range "aé世" {
+		n = n + i + int(r)
+	}
+	if n != 'a'+1+'é'+3+'世' || '\u00e9' != 'é' || "\U0001F600" != "😀" {
+		error("fail: range over string or Unicode literals")
+	}
+}
+
+func testLiterals() {
+	if 0x1f != 31 || 0o17 != 15 || 0b101 != 5 || 0755 != 493 || 1_000 != 1000 ||
+		"\x41\101A" != "AAA" || `a\n` != "a\\n" || '\a' != 7 || '\377' != 255 {
+		error("fail: literal syntax")
+	}
+	if 0xFFFF_FFFF_FFFF_FFFF != uint64(18446744073709551615) || 1_0.2_5e0_1 != 102.5 {
+		error("fail: large or separated literals")
+	}
+}
+
+func testBranches() {
+	n := 0
+	for {
+		n++
+		if n < 3 {
+			continue
+		}
+		break /* block comment
+		acts as newline */
+	}
+	if n != 3 {
+		error("fail: break, continue or ++")
+	}
+}
+
+func testGoto() {
+	n := 0
+outer:
+	for _, r := range "abc" {
+		for range "xy" {
+			n++
+			if r == 'b' {
+				continue outer
+			}
+			if n > 4 {
+				goto done
+			}
+		}
+	}
+done:
+	if n != 5 {
+		error("fail: goto or labeled continue")
+	}
+}
+
+func testFloat() {
+	f := 1.5
+	g := f*2 + 0.25 // untyped constants convert to float64
+	if g != 3.25 || f/2 != 0.75 || g-f != 1.75 || -f >= 0 || f < 1 || f > g {
+		error("fail: float64 arithmetic or comparison")
+	}
+	i := int(g)
+	if i != 3 || int(-g) != -3 || float64(i)+f != 4.5 || float64(7)/2 != 3.5 {
+		error("fail: float64 conversion")
+	}
+	if 0.1+0.2 != 0.3 || 7/2 != 3 || 7/2.0 != 3.5 || -7%3 != -1 {
+		error("fail: constant arithmetic")
+	}
+}
+
+func testTypes() {
+	names := testNames(testSlice)
+	names.add("a")
+	if names.add("b") != 2 || names.last() != "b" {
+		error("fail: methods")
+	}
+	q, r := testDivMod(17, 5)
+	q, r = r, q
+	if q != 2 || r != 3 {
+		error("fail: multiple results or assignment")
+	}
+}
+
+func testMake() {
+	s := make([]int, 2, 5)
+	n := copy(s, append(testInts, 7, 8, 9))
+	b := make([]byte, 3)
+	if len(s) != 2 || cap(s) != 5 || n != 2 || s[1] != 8 ||
+		copy(b, "hi") != 2 || b[1] != 'i' || b[2] != 0 {
+		error("fail: make, copy or cap")
+	}
+}
+
+func testNil() {
+	var s []string
+	var n int
+	var t = append(s, "x")
+	if s != nil || n != 0 || len(s) != 0 || t == nil || nil != testInts ||
+		[]int(nil) != nil {
+		error("fail: nil slice or zero value")
+	}
+	s = nil
+	var f func(string) int = nil
+	if len(append(s, t...)) != 1 || f != nil {
+		error("fail: nil assignment")
+	}
+}
+
+func testCompare() {
+	if "a" >= "b" || "ab" >= "abc" || "b" <= "abc" || "" > "" || "\xff" < "\x7f" {
+		error("fail: string comparison")
+	}
+}
+
+func testBounds() {
+	s := make([]int, 2, 3)
+	s[1] = 5
+	s = s[:3]
+	if s[1] != 5 || s[2] != 0 || "ab"[1] != 'b' {
+		error("fail: indexing at bounds")
+	}
+}
+
+func testDivide() {
+	m := -9223372036854775807 - 1
+	n := -1
+	if m/n != m || m%n != 0 || -7/2 != -3 || -7%2 != -1 {
+		error("fail: division")
+	}
+}
+
+func usage() {
+	log("usage: mugo [-B] [-V] [-G] [-P] [-H megabytes] [file.go ...] >prog.asm\n")
+	exit(2)
+}
+
+// Report whether command-line argument arg is a flag (starts with "-").
+func isFlag(arg string) bool {
+	if len(arg) == 0 {
+		return false
+	}
+	return arg[0] == '-'
+}
+
+// Return s (a positive decimal number of megabytes) in bytes, or 0 if s is
+// not a valid size.
+func parseMegabytes(s string) int {
+	n := 0
+	i := 0
+	for i < len(s) {
+		if s[i] < '0' || s[i] > '9' || n > 1048576 {
+			return 0
+		}
+		n = n*10 + int(s[i]-'0')
+		i = i + 1
+	}
+	return n * 1048576
+}
+
 func main() {
 	// Builtin functions (defined in genProgramStart; Go versions in gofuncs.go)
-	addFunc("print", typeVoid, 1, typeString, 0)
-	addFunc("log", typeVoid, 1, typeString, 0)
-	addFunc("getc", typeInt, 0, 0, 0)
-	addFunc("exit", typeVoid, 1, typeInt, 0)
-	addFunc("char", typeString, 1, typeInt, 0)
-	addFunc("len", typeInt, 1, typeString, 0)
-	addFunc("_lenSlice", typeInt, 1, typeSliceInt, 0) // works with typeSliceStr too
-	addFunc("int", typeInt, 1, typeInt, 0)
-	addFunc("append", typeSliceInt, 2, typeSliceInt, typeInt)
-	addFunc("_appendInt", typeSliceInt, 2, typeSliceInt, typeInt)
-	addFunc("_appendString", typeSliceStr, 2, typeSliceStr, typeString)
+	addFunc("print", typeVoid, typeString)
+	addFunc("log", typeVoid, typeString)
+	addFunc("getc", typeInt)
+	addFunc("exit", typeVoid, typeInt)
+	addFunc("args", typeSliceStr)
+	addFunc("_environ", typeSliceStr)
+	addFunc("_gc", typeVoid)
+	addFunc("_openFile", typeInt, typeString, typeInt, typeInt)
+	addFunc("_read", typeInt, typeInt, typeSliceByt)
+	addFunc("_write", typeInt, typeInt, typeString)
+	addFunc("_close", typeInt, typeInt)
+	addFunc("_stat", typeInt, typeString)
+	addFunc("_readFile", typeString, typeString)
+	addFunc("char", typeString, typeInt)
+	addFunc("_lenSlice", typeInt, typeSliceInt) // works with typeSliceStr too
+	addFunc("_floatBits", typeInt, typeFloat)
+	addFunc("_uintToFloat", typeFloat, typeUint64)
+	addFunc("_floatToUint", typeUint64, typeFloat)
+	addFunc("_appendByte", typeSliceByt, typeSliceByt, typeUint8)
+	addFunc("_appendBytes", typeSliceByt, typeSliceByt, typeSliceByt)
+	addFunc("_stringToBytes", typeSliceByt, typeString)
+	addFunc("_bytesToString", typeString, typeSliceByt)
+	addFunc("_runeToString", typeString, typeInt32)
+	addFunc("_appendInt", typeSliceInt, typeSliceInt, typeInt)
+	addFunc("_appendString", typeSliceStr, typeSliceStr, typeString)
+	addFunc("_appendInts", typeSliceInt, typeSliceInt, typeSliceInt)
+	addFunc("_appendStrings", typeSliceStr, typeSliceStr, typeSliceStr)
+	addFunc("_newError", typeError, typeString)
+	addFunc("error.Error", typeString, typeError)
 
 	// Forward references
-	addFunc("Expression", typeInt, 0, 0, 0)
-	addFunc("Block", typeVoid, 0, 0, 0)
+	addFunc("Expression", typeInt)
+	addFunc("Block", typeVoid)
+	addFunc("Statement", typeVoid)
+	addFunc("Type", typeInt)
+	addFunc("FuncType", typeInt)
+	addFunc("subst", typeInt, typeInt, typeInt, typeInt, typeInt)
 
 	// Token names
 	addToken("") // token 0 is not valid
@@ -1600,8 +7332,17 @@ func main() {
 	addToken("func")
 	addToken("return")
 	addToken("package")
+	addToken("import")
+	addToken("type")
+	addToken("range")
+	addToken("break")
+	addToken("continue")
+	addToken("fallthrough")
+	addToken("goto")
 	addToken("integer")
 	addToken("string")
+	addToken("float")
+	addToken("character")
 	addToken("identifier")
 	addToken("EOF")
 	addToken("||")
@@ -1611,24 +7352,114 @@ func main() {
 	addToken("<=")
 	addToken(">=")
 	addToken(":=")
+	addToken("...")
+	addToken("++")
+	addToken("--")
 
 	// Type names and sizes
-	addType("", 0) // type 0 is not valid
-	addType("void", 0)
-	addType("int", 8)
-	addType("string", 16)
-	addType("[]int", 24)
-	addType("[]string", 24)
-
+	newType("", 0, 0, 0) // type 0 is not valid
+	newType("void", 0, kindBasic, 0)
+	newType("int", 8, kindBasic, 0)
+	newType("string", 16, kindBasic, 0)
+	newType("[]int", 24, kindSlice, typeInt)
+	newType("[]string", 24, kindSlice, typeString)
+	newType("any", 16, kindBasic, 0)
+	newType("bool", 8, kindBasic, 0)
+	newType("error", 8, kindBasic, 0)
+	newType("untyped nil", 8, kindBasic, 0)
+	newType("[]any", 24, kindSlice, typeAny)
+	newType("float64", 8, kindBasic, 0)
+	newType("[]float64", 24, kindSlice, typeFloat)
+	newType("int8", 8, kindBasic, 0)
+	newType("int16", 8, kindBasic, 0)
+	newType("int32", 8, kindBasic, 0)
+	newType("int64", 8, kindBasic, 0)
+	newType("uint8", 8, kindBasic, 0)
+	newType("uint16", 8, kindBasic, 0)
+	newType("uint32", 8, kindBasic, 0)
+	newType("uint64", 8, kindBasic, 0)
+	newType("uint", 8, kindBasic, 0)
+	newType("uintptr", 8, kindBasic, 0)
+	newType("[]uint8", 24, kindSlice, typeUint8)
+
+	// Built-in generic functions
+	typ := addGeneric("append")
+	addFunc("append", sliceType(typ), sliceType(typ), sliceType(typ))
+	funcVariadics[len(funcVariadics)-1] = typ
+	typ = addGeneric("len")
+	addFunc("len", typeInt, sliceType(typ))
+	typ = addGeneric("cap")
+	addFunc("cap", typeInt, sliceType(typ))
+	typ = addGeneric("copy")
+	addFunc("copy", typeInt, sliceType(typ), sliceType(typ))
+	typ = addGeneric("make")
+	addFunc("make", sliceType(typ), typeInt, typeInt)
+
+	fileNames = append(fileNames, "") // stdin, if no files are given
 	testUnused()
+	testVariadic()
+	testGenerics()
+	testTypes()
+	testFloat()
+	testSized()
+	testBytes()
+	testRunes()
+	testLiterals()
+	testBranches()
+	testGoto()
+	testMake()
+	testNil()
+	testCompare()
+	testBounds()
+	testDivide()
+
+	heapLimit = maxHeap * 1048576
+	argv := args()
+	i := 1
+	for i < len(argv) {
+		arg := argv[i]
+		if arg == "-B" {
+			noBounds = 1 // disable bounds checks
+		} else if arg == "-V" {
+			checkOverflow = 1 // trap signed integer overflow
+		} else if arg == "-G" {
+			gcStats = 1 // print garbage collector statistics at exit
+		} else if arg == "-P" {
+			allocProfile = 1 // print allocation profile at exit
+		} else if arg == "-H" {
+			i = i + 1
+			heapLimit = 0
+			if i < len(argv) {
+				heapLimit = parseMegabytes(argv[i])
+			}
+			if heapLimit == 0 {
+				usage()
+			}
+		} else if isFlag(arg) {
+			usage()
+		} else {
+			fileNames = append(fileNames, arg)
+		}
+		i = i + 1
+	}
 
 	genProgramStart()
 
+	// Predeclared constants
+	consts = append(consts, "false", "true")
+	constTypes = append(constTypes, typeBool, typeBool)
+	constUntyped = append(constUntyped, false, false)
+	constValues = append(constValues, "0", "1")
+	genConst("false", 0)
+	genConst("true", 1)
+
 	line = 1
 	col = 0
 	nextChar()
 	next()
-	SourceFile()
+	SourceFiles()
+	print("_codeEnd:\n")
 
+	genInit()
 	genDataSections()
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"os"
 	"testing"
 )
 
 // For testing code coverage
 func TestMain(t *testing.T) {
+	os.Args = os.Args[:1] // don't pass "go test" flags to Mugo
 	main()
 }